@@ -2,6 +2,7 @@ package main
 
 import (
 	"aliyun_exporter.go/collector"
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -9,8 +10,11 @@ import (
 	"github.com/prometheus/common/promlog/flag"
 	"github.com/prometheus/exporter-toolkit/web"
 	"gopkg.in/alecthomas/kingpin.v2"
+	stdlog "log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 const version string = "1.0.4"
@@ -18,6 +22,7 @@ const version string = "1.0.4"
 var (
 	listenAddress = kingpin.Flag("web.listen-address", "Address to listen on for web interface.").Default(":9233").String()
 	metricsPath   = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+	configFile    = kingpin.Flag("config.file", "Path to the yaml configuration file.").Default("").String()
 )
 
 func main() {
@@ -28,14 +33,73 @@ func main() {
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
 
+	if err := collector.LoadConfig(*configFile); err != nil {
+		level.Error(logger).Log("msg", "Error loading config", "file", *configFile, "err", err)
+		os.Exit(1)
+	}
+
+	hup := make(chan os.Signal, 1)
+	reloadCh := make(chan chan error)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if err := collector.LoadConfig(*configFile); err != nil {
+					level.Error(logger).Log("msg", "Error reloading config", "file", *configFile, "err", err)
+					continue
+				}
+				level.Info(logger).Log("msg", "Reloaded config file", "file", *configFile)
+			case rc := <-reloadCh:
+				if err := collector.LoadConfig(*configFile); err != nil {
+					level.Error(logger).Log("msg", "Error reloading config", "file", *configFile, "err", err)
+					rc <- err
+					continue
+				}
+				level.Info(logger).Log("msg", "Reloaded config file", "file", *configFile)
+				rc <- nil
+			}
+		}
+	}()
+
 	reg := prometheus.NewRegistry()
-	reg.MustRegister(collector.NewSlbCollector())
-	reg.MustRegister(collector.NewNatCollector())
-	reg.MustRegister(collector.NewEipCollector())
-	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	slbCollector := collector.NewCachedCollector("acs_slb_dashboard", collector.NewSlbCollector())
+	natCollector := collector.NewCachedCollector("acs_nat_gateway", collector.NewNatCollector())
+	eipCollector := collector.NewCachedCollector("acs_vpc_eip", collector.NewEipCollector())
+	slbInventoryCollector := collector.NewCachedCollector("slb_inventory", collector.NewSlbInventoryCollector())
+	slbBackendCollector := collector.NewCachedCollector("slb_backend", collector.NewSlbBackendCollector())
+	reg.MustRegister(collector.ExporterMetrics()...)
+	collector.StartPolling(slbCollector, natCollector, eipCollector, slbInventoryCollector, slbBackendCollector)
+
+	// 个别重复或不一致的指标只记录日志并跳过，不影响其它指标的输出
+	handlerOpts := promhttp.HandlerOpts{
+		ErrorLog:      stdlog.New(log.NewStdlibAdapter(level.Error(logger)), "", 0),
+		ErrorHandling: promhttp.ContinueOnError,
+	}
 
 	level.Info(logger).Log("msg", "Starting aliyun_exporter", "version", version)
-	http.Handle("/metrics", h)
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		// 每次抓取使用新的 Registry，根据 Prometheus 的抓取超时控制采集时间；
+		// 先采集云监控指标，再输出本次采集更新后的自身指标
+		ctx, cancel := collector.ScrapeContext(r)
+		defer cancel()
+		scrapeReg := prometheus.NewRegistry()
+		scrapeReg.MustRegister(collector.WithContext(ctx, slbCollector, natCollector, eipCollector, slbInventoryCollector, slbBackendCollector))
+		promhttp.HandlerFor(prometheus.Gatherers{scrapeReg, reg}, handlerOpts).ServeHTTP(w, r)
+	})
+	http.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("This endpoint requires a POST request.\n"))
+			return
+		}
+
+		rc := make(chan error)
+		reloadCh <- rc
+		if err := <-rc; err != nil {
+			http.Error(w, "failed to reload config: "+err.Error(), http.StatusInternalServerError)
+		}
+	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
             <head><title>Aliyun Exporter</title></head>
@@ -1,31 +1,39 @@
 package collector
 
 import (
-	"encoding/base64"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
 	cms20190101 "github.com/alibabacloud-go/cms-20190101/v2/client"
 	openapi "github.com/alibabacloud-go/darabonba-openapi/client"
 	slb20140515 "github.com/alibabacloud-go/slb-20140515/v3/client"
+	util "github.com/alibabacloud-go/tea-utils/service"
 	"github.com/alibabacloud-go/tea/tea"
 	vpc20160428 "github.com/alibabacloud-go/vpc-20160428/v2/client"
-	"github.com/go-kit/log/level"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	AccessKeyId     = kingpin.Flag("access.keyid", "The aliyun AccessKeyId, use base64 encode").Default("Default").String()
-	AccessKeySecret = kingpin.Flag("accesss.key.secret", "The aliyun AccessKeySecret, use base64 encode").Default("Default").String()
-	regionId        = kingpin.Flag("region.id", "The aliyun regionid").Default("Default, like cn-zhangjiakou").String()
-	endpoint        = kingpin.Flag("endpoint", "The aliyun endpoint").Default("metrics.cn-hangzhou.aliyuncs.com").String()
+	AccessKeyId      = kingpin.Flag("access.keyid", "The aliyun AccessKeyId, use base64 encode. Prefer environment variables or the credentials file, command line arguments are visible in ps").Default("").String()
+	AccessKeySecret  = kingpin.Flag("accesss.key.secret", "The aliyun AccessKeySecret, use base64 encode").Default("").String()
+	credentialsFile  = kingpin.Flag("credentials.file", "The aliyun cli credentials file, default ~/.aliyun/config.json").Default("").String()
+	metadataEndpoint = kingpin.Flag("metadata.endpoint", "The ecs instance metadata endpoint used to get ram role credentials").Default("http://100.100.100.200").String()
+	stsEndpoint      = kingpin.Flag("sts.endpoint", "The aliyun sts endpoint used by AssumeRole, like sts.aliyuncs.com or http://127.0.0.1:8080").Default("sts.aliyuncs.com").String()
+	regionId         = kingpin.Flag("region.id", "The aliyun regionid, like cn-zhangjiakou, multiple regions separated by commas").Default("").String()
+	endpoint         = kingpin.Flag("endpoint", "The aliyun cms endpoint, default metrics.<region.id>.aliyuncs.com").Default("").String()
 )
 
+var errEmptyResponse = errors.New("empty response from aliyun api")
+
+// CreateClient 不设置凭证，凭证在每次调用时由 bindCredential 绑定到复制的 client 上
 func CreateClient(endpoint *string) (config *openapi.Config) {
-	accesskeyid, _ := base64.StdEncoding.DecodeString(*AccessKeyId)
-	accesskeysecret, _ := base64.StdEncoding.DecodeString(*AccessKeySecret)
+	apiConf := currentConfig().API
 	config = &openapi.Config{
-		// 您的AccessKey ID
-		AccessKeyId: tea.String(string(accesskeyid)),
-		// 您的AccessKey Secret
-		AccessKeySecret: tea.String(string(accesskeysecret)),
+		// 单位毫秒
+		ReadTimeout:    tea.Int(int(apiConf.timeout().Milliseconds())),
+		ConnectTimeout: tea.Int(int(apiConf.connectTimeout().Milliseconds())),
 	}
 	// 访问的域名
 	config.Endpoint = endpoint
@@ -33,64 +41,593 @@ func CreateClient(endpoint *string) (config *openapi.Config) {
 	return config
 }
 
-func describeMetricLastResponse(metrics string, namespace string) (*cms20190101.DescribeMetricLastResponse, error) {
-	config := CreateClient(
-		tea.String(*endpoint),
-	)
-	client := &cms20190101.Client{}
-	client, _err := cms20190101.NewClient(config)
+func cmsEndpoint(region string) string {
+	if *endpoint != "" {
+		return *endpoint
+	}
+	return "metrics." + region + ".aliyuncs.com"
+}
+
+func slbEndpoint(region string) string {
+	return "slb." + region + ".aliyuncs.com"
+}
+
+func vpcEndpoint(region string) string {
+	return "vpc." + region + ".aliyuncs.com"
+}
+
+// 配额中心只有一个 endpoint，不区分地域
+func quotasEndpoint() string {
+	return "quotas.aliyuncs.com"
+}
+
+// describeMetricLastDatapoints 按 NextToken 翻页获取全部数据点，pageLength 为空时使用接口默认的每页条数
+func describeMetricLastDatapoints(ctx context.Context, account Account, metrics string, namespace string, region string, period string, pageLength string) ([]datapoint, error) {
+	client, _err := cmsClient(account, region)
 	if _err != nil {
-		level.Error(logger).Log("msg", _err)
+		return nil, _err
 	}
 
 	describeMetricLastRequest := &cms20190101.DescribeMetricLastRequest{
 		Namespace:  tea.String(namespace),
 		MetricName: tea.String(metrics),
-		Period:     tea.String("60"),
+		Period:     tea.String(period),
+		RegionId:   tea.String(region),
+	}
+	if pageLength != "" {
+		describeMetricLastRequest.Length = tea.String(pageLength)
+	}
+
+	var datapoints []datapoint
+	for {
+		var dataResponse *cms20190101.DescribeMetricLastResponse
+		_err := callAPI(ctx, "DescribeMetricLast", namespace, cmsEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeMetricLast(describeMetricLastRequest)
+			if _err != nil {
+				return "", _err
+			}
+			if dataResponse == nil || dataResponse.Body == nil {
+				return "error", errEmptyResponse
+			}
+			return tea.StringValue(dataResponse.Body.Code), nil
+		})
+		if _err != nil {
+			return datapoints, _err
+		}
+		cmsPagesTotal.WithLabelValues(namespace, metrics).Inc()
+
+		if tea.StringValue(dataResponse.Body.Code) != "200" {
+			return datapoints, fmt.Errorf("the result returned by the server is not 200, code: %s, message: %s",
+				tea.StringValue(dataResponse.Body.Code), tea.StringValue(dataResponse.Body.Message))
+		}
+
+		if dataResponse.Body.Datapoints != nil {
+			page, skipped, _err := decodeDatapoints(*dataResponse.Body.Datapoints)
+			if _err != nil {
+				return datapoints, _err
+			}
+			datapointsSkippedTotal.WithLabelValues(namespace, metrics).Add(float64(skipped))
+			datapoints = append(datapoints, page...)
+		}
+
+		nextToken := tea.StringValue(dataResponse.Body.NextToken)
+		if nextToken == "" || nextToken == tea.StringValue(describeMetricLastRequest.NextToken) {
+			return datapoints, nil
+		}
+		describeMetricLastRequest.NextToken = tea.String(nextToken)
+	}
+}
+
+// 实例列表接口每页最多返回 100 条
+const inventoryPageSize = 100
+
+type loadBalancer = slb20140515.DescribeLoadBalancersResponseBodyLoadBalancersLoadBalancer
+type eipAddress = vpc20160428.DescribeEipAddressesResponseBodyEipAddressesEipAddress
+type listener = slb20140515.DescribeLoadBalancerListenersResponseBodyListeners
+type backendHealth = slb20140515.DescribeHealthStatusResponseBodyBackendServersBackendServer
+type backendServer = slb20140515.DescribeLoadBalancerAttributeResponseBodyBackendServersBackendServer
+type vServerGroup = slb20140515.DescribeVServerGroupsResponseBodyVServerGroupsVServerGroup
+type serverCertificate = slb20140515.DescribeServerCertificatesResponseBodyServerCertificatesServerCertificate
+type caCertificate = slb20140515.DescribeCACertificatesResponseBodyCACertificatesCACertificate
+type vServerGroupBackendServer = slb20140515.DescribeVServerGroupAttributeResponseBodyBackendServersBackendServer
+type accessControlList = slb20140515.DescribeAccessControlListsResponseBodyAclsAcl
+type accessControlListAttribute = slb20140515.DescribeAccessControlListAttributeResponseBody
+type masterSlaveServerGroup = slb20140515.DescribeMasterSlaveServerGroupsResponseBodyMasterSlaveServerGroupsMasterSlaveServerGroup
+type masterSlaveBackendServer = slb20140515.DescribeMasterSlaveServerGroupAttributeResponseBodyMasterSlaveBackendServersMasterSlaveBackendServer
+type domainExtension = slb20140515.DescribeDomainExtensionsResponseBodyDomainExtensionsDomainExtension
+
+func describeLoadBalancers(ctx context.Context, account Account, region string) ([]*loadBalancer, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	var loadBalancers []*loadBalancer
+	for pageNumber := int32(1); ; pageNumber++ {
+		describeLoadBalancersRequest := &slb20140515.DescribeLoadBalancersRequest{
+			RegionId:   tea.String(region),
+			PageNumber: tea.Int32(pageNumber),
+			PageSize:   tea.Int32(inventoryPageSize),
+		}
+		var dataResponse *slb20140515.DescribeLoadBalancersResponse
+		_err := callAPI(ctx, "DescribeLoadBalancers", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeLoadBalancers(describeLoadBalancersRequest)
+			return "", _err
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if dataResponse == nil || dataResponse.Body == nil {
+			return nil, errEmptyResponse
+		}
+
+		var page []*loadBalancer
+		if dataResponse.Body.LoadBalancers != nil {
+			page = dataResponse.Body.LoadBalancers.LoadBalancer
+		}
+		loadBalancers = append(loadBalancers, page...)
+		if len(page) < inventoryPageSize || len(loadBalancers) >= int(tea.Int32Value(dataResponse.Body.TotalCount)) {
+			return loadBalancers, nil
+		}
+	}
+}
+
+func describeEipAddresses(ctx context.Context, account Account, region string) ([]*eipAddress, error) {
+	client, _err := vpcClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	var eips []*eipAddress
+	for pageNumber := int32(1); ; pageNumber++ {
+		describeEipAddressesRequest := &vpc20160428.DescribeEipAddressesRequest{
+			RegionId:   tea.String(region),
+			PageNumber: tea.Int32(pageNumber),
+			PageSize:   tea.Int32(inventoryPageSize),
+		}
+		var dataResponse *vpc20160428.DescribeEipAddressesResponse
+		_err := callAPI(ctx, "DescribeEipAddresses", "acs_vpc_eip", vpcEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeEipAddresses(describeEipAddressesRequest)
+			return "", _err
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if dataResponse == nil || dataResponse.Body == nil {
+			return nil, errEmptyResponse
+		}
+
+		var page []*eipAddress
+		if dataResponse.Body.EipAddresses != nil {
+			page = dataResponse.Body.EipAddresses.EipAddress
+		}
+		eips = append(eips, page...)
+		if len(page) < inventoryPageSize || len(eips) >= int(tea.Int32Value(dataResponse.Body.TotalCount)) {
+			return eips, nil
+		}
+	}
+}
+
+// describeLoadBalancerListeners 按 NextToken 获取地域下全部 slb 的监听，返回中已包含各协议的监听配置，
+// 不需要再逐个调用 DescribeLoadBalancer*ListenerAttribute
+func describeLoadBalancerListeners(ctx context.Context, account Account, region string) ([]*listener, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
 	}
-	dataResponse, _err := client.DescribeMetricLast(describeMetricLastRequest)
 
-	return dataResponse, _err
+	describeLoadBalancerListenersRequest := &slb20140515.DescribeLoadBalancerListenersRequest{
+		RegionId:   tea.String(region),
+		MaxResults: tea.Int32(inventoryPageSize),
+	}
+	var listeners []*listener
+	for {
+		var dataResponse *slb20140515.DescribeLoadBalancerListenersResponse
+		_err := callAPI(ctx, "DescribeLoadBalancerListeners", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeLoadBalancerListeners(describeLoadBalancerListenersRequest)
+			return "", _err
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if dataResponse == nil || dataResponse.Body == nil {
+			return nil, errEmptyResponse
+		}
+
+		listeners = append(listeners, dataResponse.Body.Listeners...)
+		nextToken := tea.StringValue(dataResponse.Body.NextToken)
+		if nextToken == "" || nextToken == tea.StringValue(describeLoadBalancerListenersRequest.NextToken) {
+			return listeners, nil
+		}
+		describeLoadBalancerListenersRequest.NextToken = tea.String(nextToken)
+	}
 }
 
-func describeLoadBalancersResponse() *slb20140515.DescribeLoadBalancersResponse {
-	config := CreateClient(
-		tea.String("slb."+*regionId+".aliyuncs.com"),
-	)
-	client := &slb20140515.Client{}
-	client, _err := slb20140515.NewClient(config)
+// describeHealthStatus 返回 slb 所有监听下后端服务器的健康检查状态
+func describeHealthStatus(ctx context.Context, account Account, region string, loadBalancerId string) ([]*backendHealth, error) {
+	client, _err := slbClient(account, region)
 	if _err != nil {
-		level.Error(logger).Log("msg", _err)
+		return nil, _err
 	}
 
-	describeLoadBalancersRequest := &slb20140515.DescribeLoadBalancersRequest{
-		RegionId: tea.String(*regionId),
+	describeHealthStatusRequest := &slb20140515.DescribeHealthStatusRequest{
+		RegionId:       tea.String(region),
+		LoadBalancerId: tea.String(loadBalancerId),
+	}
+	var dataResponse *slb20140515.DescribeHealthStatusResponse
+	_err = callAPI(ctx, "DescribeHealthStatus", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeHealthStatus(describeHealthStatusRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.BackendServers == nil {
+		return nil, nil
 	}
-	dataResponse, _err := client.DescribeLoadBalancers(describeLoadBalancersRequest)
+	return dataResponse.Body.BackendServers.BackendServer, nil
+}
+
+// describeBackendServers 返回 slb 默认服务器组中的后端服务器
+func describeBackendServers(ctx context.Context, account Account, region string, loadBalancerId string) ([]*backendServer, error) {
+	client, _err := slbClient(account, region)
 	if _err != nil {
-		level.Error(logger).Log("msg", _err)
+		return nil, _err
 	}
 
-	return dataResponse
+	describeLoadBalancerAttributeRequest := &slb20140515.DescribeLoadBalancerAttributeRequest{
+		RegionId:       tea.String(region),
+		LoadBalancerId: tea.String(loadBalancerId),
+	}
+	var dataResponse *slb20140515.DescribeLoadBalancerAttributeResponse
+	_err = callAPI(ctx, "DescribeLoadBalancerAttribute", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeLoadBalancerAttribute(describeLoadBalancerAttributeRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.BackendServers == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.BackendServers.BackendServer, nil
 }
 
-func describeEipAddressesResponse() *vpc20160428.DescribeEipAddressesResponse {
-	config := CreateClient(
-		tea.String("vpc."+*regionId+".aliyuncs.com"),
-	)
-	client := &vpc20160428.Client{}
-	client, _err := vpc20160428.NewClient(config)
+// describeVServerGroups 返回 slb 的虚拟服务器组及其关联的监听
+func describeVServerGroups(ctx context.Context, account Account, region string, loadBalancerId string) ([]*vServerGroup, error) {
+	client, _err := slbClient(account, region)
 	if _err != nil {
-		level.Error(logger).Log("msg", _err)
+		return nil, _err
 	}
 
-	describeEipAddressesRequest := &vpc20160428.DescribeEipAddressesRequest{
-		RegionId: tea.String(*regionId),
+	describeVServerGroupsRequest := &slb20140515.DescribeVServerGroupsRequest{
+		RegionId:        tea.String(region),
+		LoadBalancerId:  tea.String(loadBalancerId),
+		IncludeListener: tea.Bool(true),
 	}
-	dataResponse, _err := client.DescribeEipAddresses(describeEipAddressesRequest)
+	var dataResponse *slb20140515.DescribeVServerGroupsResponse
+	_err = callAPI(ctx, "DescribeVServerGroups", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeVServerGroups(describeVServerGroupsRequest)
+		return "", _err
+	})
 	if _err != nil {
-		level.Error(logger).Log("msg", _err)
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.VServerGroups == nil {
+		return nil, nil
 	}
+	return dataResponse.Body.VServerGroups.VServerGroup, nil
+}
 
-	return dataResponse
+// describeVServerGroupBackendServers 返回虚拟服务器组中的后端服务器
+func describeVServerGroupBackendServers(ctx context.Context, account Account, region string, vServerGroupId string) ([]*vServerGroupBackendServer, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeVServerGroupAttributeRequest := &slb20140515.DescribeVServerGroupAttributeRequest{
+		RegionId:       tea.String(region),
+		VServerGroupId: tea.String(vServerGroupId),
+	}
+	var dataResponse *slb20140515.DescribeVServerGroupAttributeResponse
+	_err = callAPI(ctx, "DescribeVServerGroupAttribute", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeVServerGroupAttribute(describeVServerGroupAttributeRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.BackendServers == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.BackendServers.BackendServer, nil
+}
+
+// describeServerCertificates 返回地域下上传到 slb 的服务器证书
+func describeServerCertificates(ctx context.Context, account Account, region string) ([]*serverCertificate, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeServerCertificatesRequest := &slb20140515.DescribeServerCertificatesRequest{
+		RegionId: tea.String(region),
+	}
+	var dataResponse *slb20140515.DescribeServerCertificatesResponse
+	_err = callAPI(ctx, "DescribeServerCertificates", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeServerCertificates(describeServerCertificatesRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.ServerCertificates == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.ServerCertificates.ServerCertificate, nil
+}
+
+// describeCACertificates 返回地域下上传到 slb 的 CA 证书
+func describeCACertificates(ctx context.Context, account Account, region string) ([]*caCertificate, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeCACertificatesRequest := &slb20140515.DescribeCACertificatesRequest{
+		RegionId: tea.String(region),
+	}
+	var dataResponse *slb20140515.DescribeCACertificatesResponse
+	_err = callAPI(ctx, "DescribeCACertificates", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeCACertificates(describeCACertificatesRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.CACertificates == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.CACertificates.CACertificate, nil
+}
+
+// describeMasterSlaveServerGroups 返回 slb 的主备服务器组及其关联的监听
+func describeMasterSlaveServerGroups(ctx context.Context, account Account, region string, loadBalancerId string) ([]*masterSlaveServerGroup, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeMasterSlaveServerGroupsRequest := &slb20140515.DescribeMasterSlaveServerGroupsRequest{
+		RegionId:        tea.String(region),
+		LoadBalancerId:  tea.String(loadBalancerId),
+		IncludeListener: tea.Bool(true),
+	}
+	var dataResponse *slb20140515.DescribeMasterSlaveServerGroupsResponse
+	_err = callAPI(ctx, "DescribeMasterSlaveServerGroups", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeMasterSlaveServerGroups(describeMasterSlaveServerGroupsRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.MasterSlaveServerGroups == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.MasterSlaveServerGroups.MasterSlaveServerGroup, nil
+}
+
+// describeMasterSlaveBackendServers 返回主备服务器组中的后端服务器
+func describeMasterSlaveBackendServers(ctx context.Context, account Account, region string, groupId string) ([]*masterSlaveBackendServer, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeMasterSlaveServerGroupAttributeRequest := &slb20140515.DescribeMasterSlaveServerGroupAttributeRequest{
+		RegionId:                 tea.String(region),
+		MasterSlaveServerGroupId: tea.String(groupId),
+	}
+	var dataResponse *slb20140515.DescribeMasterSlaveServerGroupAttributeResponse
+	_err = callAPI(ctx, "DescribeMasterSlaveServerGroupAttribute", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeMasterSlaveServerGroupAttribute(describeMasterSlaveServerGroupAttributeRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.MasterSlaveBackendServers == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.MasterSlaveBackendServers.MasterSlaveBackendServer, nil
+}
+
+// DescribeAccessControlLists 每页最多返回 50 条
+const aclPageSize = 50
+
+// describeAccessControlLists 返回地域下全部的访问控制策略组
+func describeAccessControlLists(ctx context.Context, account Account, region string) ([]*accessControlList, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	var acls []*accessControlList
+	for pageNumber := int32(1); ; pageNumber++ {
+		describeAccessControlListsRequest := &slb20140515.DescribeAccessControlListsRequest{
+			RegionId:   tea.String(region),
+			PageNumber: tea.Int32(pageNumber),
+			PageSize:   tea.Int32(aclPageSize),
+		}
+		var dataResponse *slb20140515.DescribeAccessControlListsResponse
+		_err := callAPI(ctx, "DescribeAccessControlLists", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeAccessControlLists(describeAccessControlListsRequest)
+			return "", _err
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if dataResponse == nil || dataResponse.Body == nil {
+			return nil, errEmptyResponse
+		}
+
+		var page []*accessControlList
+		if dataResponse.Body.Acls != nil {
+			page = dataResponse.Body.Acls.Acl
+		}
+		acls = append(acls, page...)
+		if len(page) < aclPageSize || len(acls) >= int(tea.Int32Value(dataResponse.Body.TotalCount)) {
+			return acls, nil
+		}
+	}
+}
+
+// describeAccessControlListAttribute 返回访问控制策略组的条目和关联的监听
+func describeAccessControlListAttribute(ctx context.Context, account Account, region string, aclId string) (*accessControlListAttribute, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeAccessControlListAttributeRequest := &slb20140515.DescribeAccessControlListAttributeRequest{
+		RegionId: tea.String(region),
+		AclId:    tea.String(aclId),
+	}
+	var dataResponse *slb20140515.DescribeAccessControlListAttributeResponse
+	_err = callAPI(ctx, "DescribeAccessControlListAttribute", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeAccessControlListAttribute(describeAccessControlListAttributeRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	return dataResponse.Body, nil
+}
+
+// describeDomainExtensions 返回 https 监听的扩展域名及其使用的证书
+func describeDomainExtensions(ctx context.Context, account Account, region string, loadBalancerId string, listenerPort int32) ([]*domainExtension, error) {
+	client, _err := slbClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	describeDomainExtensionsRequest := &slb20140515.DescribeDomainExtensionsRequest{
+		RegionId:       tea.String(region),
+		LoadBalancerId: tea.String(loadBalancerId),
+		ListenerPort:   tea.Int32(listenerPort),
+	}
+	var dataResponse *slb20140515.DescribeDomainExtensionsResponse
+	_err = callAPI(ctx, "DescribeDomainExtensions", "acs_slb_dashboard", slbEndpoint(region), func() (string, error) {
+		var _err error
+		dataResponse, _err = client.DescribeDomainExtensions(describeDomainExtensionsRequest)
+		return "", _err
+	})
+	if _err != nil {
+		return nil, _err
+	}
+	if dataResponse == nil || dataResponse.Body == nil {
+		return nil, errEmptyResponse
+	}
+	if dataResponse.Body.DomainExtensions == nil {
+		return nil, nil
+	}
+	return dataResponse.Body.DomainExtensions.DomainExtension, nil
+}
+
+// productQuota 配额中心 ListProductQuotas 返回的一项配额，Dimensions 为空或只有 regionId
+// 的配额适用于整个账号或地域
+type productQuota struct {
+	QuotaActionCode string
+	QuotaName       string
+	TotalQuota      float64
+	Dimensions      map[string]interface{}
+}
+
+// describeProductQuotas 按 NextToken 获取配额中心中产品的全部配额。
+// SDK 中没有配额中心的 client，与 AssumeRole 一样直接调用 RPC 接口
+func describeProductQuotas(ctx context.Context, account Account, productCode string) ([]*productQuota, error) {
+	client, _err := quotasClient(account)
+	if _err != nil {
+		return nil, _err
+	}
+
+	query := map[string]*string{
+		"ProductCode": tea.String(productCode),
+		"MaxResults":  tea.String(fmt.Sprint(inventoryPageSize)),
+	}
+	var quotas []*productQuota
+	for {
+		var response map[string]interface{}
+		_err := callAPI(ctx, "ListProductQuotas", "acs_slb_dashboard", quotasEndpoint(), func() (string, error) {
+			var _err error
+			response, _err = client.DoRPCRequest(tea.String("ListProductQuotas"), tea.String("2020-05-10"), tea.String("HTTPS"), tea.String("POST"),
+				tea.String("AK"), tea.String("json"), &openapi.OpenApiRequest{Query: query}, &util.RuntimeOptions{})
+			return "", _err
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if response["body"] == nil {
+			return nil, errEmptyResponse
+		}
+
+		content, _err := json.Marshal(response["body"])
+		if _err != nil {
+			return nil, _err
+		}
+		var body struct {
+			NextToken string
+			Quotas    []*productQuota
+		}
+		if _err := json.Unmarshal(content, &body); _err != nil {
+			return nil, _err
+		}
+		quotas = append(quotas, body.Quotas...)
+		if body.NextToken == "" || body.NextToken == tea.StringValue(query["NextToken"]) {
+			return quotas, nil
+		}
+		query["NextToken"] = tea.String(body.NextToken)
+	}
 }
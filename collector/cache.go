@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 未开启后台轮询时，多久检查一次配置是否开启了轮询
+const pollingDisabledCheckInterval = 5 * time.Second
+
+var cacheAge = prometheus.NewDesc(
+	"aliyun_exporter_cache_age_seconds",
+	"距离上次后台轮询更新缓存的时间，单位 s",
+	[]string{"namespace"},
+	nil,
+)
+
+// contextCollector 支持通过 ctx 控制采集超时的 collector
+type contextCollector interface {
+	prometheus.Collector
+	collectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+// cachedCollector 开启后台轮询时，由轮询更新缓存，Collect 直接返回缓存的指标；
+// 未开启时每次 Collect 都直接调用云监控接口
+type cachedCollector struct {
+	namespace string
+	collector contextCollector
+	mutex     sync.RWMutex
+	metrics   []prometheus.Metric
+	updated   time.Time
+}
+
+func NewCachedCollector(namespace string, collector contextCollector) *cachedCollector {
+	return &cachedCollector{
+		namespace: namespace,
+		collector: collector,
+	}
+}
+
+// Describe 不包含多个 collector 共用的 cacheAge，由 scrapeCollector 统一输出
+func (c *cachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.collector.Describe(ch)
+}
+
+func (c *cachedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectContext(context.Background(), ch)
+}
+
+func (c *cachedCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	if currentConfig().pollingInterval() == 0 {
+		c.collector.collectContext(ctx, ch)
+		return
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.updated.IsZero() {
+		return
+	}
+	for _, m := range c.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(
+		cacheAge,
+		prometheus.GaugeValue,
+		time.Since(c.updated).Seconds(),
+		c.namespace,
+	)
+}
+
+func (c *cachedCollector) refresh(ctx context.Context) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var metrics []prometheus.Metric
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+	c.collector.collectContext(ctx, ch)
+	close(ch)
+	<-done
+
+	c.mutex.Lock()
+	c.metrics = metrics
+	c.updated = time.Now()
+	c.mutex.Unlock()
+}
+
+// StartPolling 按配置文件中的 polling.interval 在后台刷新缓存，重新加载配置后立即生效。
+// namespaces 中配置的通用 collector 每轮按当前配置加入
+func StartPolling(collectors ...*cachedCollector) {
+	go func() {
+		for {
+			interval := currentConfig().pollingInterval()
+			if interval == 0 {
+				time.Sleep(pollingDisabledCheckInterval)
+				continue
+			}
+
+			// 每轮轮询不超过 interval，避免与下一轮重叠
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			var wg sync.WaitGroup
+			for _, c := range append(append([]*cachedCollector{}, collectors...), configuredNamespaceCollectors()...) {
+				wg.Add(1)
+				go func(c *cachedCollector) {
+					defer wg.Done()
+					c.refresh(ctx)
+				}(c)
+			}
+			wg.Wait()
+			cancel()
+			level.Debug(logger).Log("msg", "Polling finished", "duration", time.Since(start))
+
+			time.Sleep(interval - time.Since(start))
+		}
+	}()
+}
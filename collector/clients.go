@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	cms20190101 "github.com/alibabacloud-go/cms-20190101/v2/client"
+	openapi "github.com/alibabacloud-go/darabonba-openapi/client"
+	slb20140515 "github.com/alibabacloud-go/slb-20140515/v3/client"
+	"github.com/alibabacloud-go/tea/tea"
+	vpc20160428 "github.com/alibabacloud-go/vpc-20160428/v2/client"
+)
+
+// clientKey 按账号、地域和产品区分 SDK client
+type clientKey struct {
+	account string
+	region  string
+	product string
+}
+
+// clientSettings 创建 client 时使用的配置，变化后重新创建 client
+type clientSettings struct {
+	credential     CredentialConfig
+	endpoint       string
+	readTimeout    time.Duration
+	connectTimeout time.Duration
+}
+
+type clientEntry struct {
+	settings clientSettings
+	client   interface{}
+}
+
+var (
+	clients     = make(map[clientKey]*clientEntry)
+	clientMutex sync.Mutex
+)
+
+// sharedClient 返回账号、地域和产品对应的 client，所有 collector 共用；
+// 凭证、endpoint 或超时配置变化时调用 build 重新创建。
+// 相同超时配置的 client 共用 tea 中的 http.Client，连接会保持复用。
+// SDK 的 client 每次请求都会修改自身的 Headers，并发使用时调用方需要复制一份，
+// 并通过 bindCredential 设置凭证
+func sharedClient(account Account, region, product, endpoint string, build func(config *openapi.Config) (interface{}, error)) (interface{}, error) {
+	apiConf := currentConfig().API
+	settings := clientSettings{
+		credential:     account.CredentialConfig,
+		endpoint:       endpoint,
+		readTimeout:    apiConf.timeout(),
+		connectTimeout: apiConf.connectTimeout(),
+	}
+	key := clientKey{account: account.Name, region: region, product: product}
+
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+
+	if entry, ok := clients[key]; ok && entry.settings == settings {
+		return entry.client, nil
+	}
+	client, err := build(CreateClient(tea.String(endpoint)))
+	if err != nil {
+		return nil, err
+	}
+	clients[key] = &clientEntry{settings: settings, client: client}
+	return client, nil
+}
+
+func cmsClient(account Account, region string) (*cms20190101.Client, error) {
+	client, err := sharedClient(account, region, "cms", cmsEndpoint(region), func(config *openapi.Config) (interface{}, error) {
+		return cms20190101.NewClient(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied := *client.(*cms20190101.Client)
+	if err := bindCredential(&copied.Client, account); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+func slbClient(account Account, region string) (*slb20140515.Client, error) {
+	client, err := sharedClient(account, region, "slb", slbEndpoint(region), func(config *openapi.Config) (interface{}, error) {
+		return slb20140515.NewClient(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied := *client.(*slb20140515.Client)
+	if err := bindCredential(&copied.Client, account); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+func vpcClient(account Account, region string) (*vpc20160428.Client, error) {
+	client, err := sharedClient(account, region, "vpc", vpcEndpoint(region), func(config *openapi.Config) (interface{}, error) {
+		return vpc20160428.NewClient(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied := *client.(*vpc20160428.Client)
+	if err := bindCredential(&copied.Client, account); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+// quotasClient 配额中心没有单独的 SDK，使用 openapi 的 client 调用 RPC 接口，不区分地域
+func quotasClient(account Account) (*openapi.Client, error) {
+	client, err := sharedClient(account, "", "quotas", quotasEndpoint(), func(config *openapi.Config) (interface{}, error) {
+		return openapi.NewClient(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	copied := *client.(*openapi.Client)
+	if err := bindCredential(&copied, account); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+// bindCredential 为复制的 client 固定一份凭证，同一次调用的所有请求使用相同的 AccessKey 和 SecurityToken。
+// 凭证只在这里获取和刷新，获取失败时不发起请求
+func bindCredential(client *openapi.Client, account Account) error {
+	creds, err := accountCredential(account).get()
+	if err != nil {
+		return err
+	}
+	client.Credential = creds
+	return nil
+}
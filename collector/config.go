@@ -0,0 +1,486 @@
+package collector
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// collectorNames 配置文件 collectors 中可以使用的名称
+var collectorNames = []string{"slb", "nat", "eip", "slb_inventory", "slb_backend"}
+
+// optInCollectors 会为每个实例额外调用接口，需要在配置文件中通过 enabled: true 开启
+var optInCollectors = []string{"slb_inventory", "slb_backend"}
+
+// builtinNamespaces 内置 collector 在 aliyun_exporter_cache_age_seconds 等自身指标中使用的 namespace 标签
+var builtinNamespaces = []string{"acs_slb_dashboard", "acs_nat_gateway", "acs_vpc_eip", "slb_inventory", "slb_backend"}
+
+type Config struct {
+	Regions    []string                   `yaml:"regions"`
+	Accounts   []Account                  `yaml:"accounts"`
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+	Namespaces []NamespaceConfig          `yaml:"namespaces"`
+	Polling    PollingConfig              `yaml:"polling"`
+	API        APIConfig                  `yaml:"api"`
+}
+
+// Account 一个阿里云账号及其需要采集的地域
+type Account struct {
+	Name             string `yaml:"name"`
+	CredentialConfig `yaml:",inline"`
+	Regions          []string `yaml:"regions"`
+}
+
+// CollectorConfig 单个 collector 的开关和需要采集的指标
+type CollectorConfig struct {
+	// 默认开启，slb_inventory、slb_backend 默认关闭
+	Enabled *bool `yaml:"enabled"`
+	// 为空时采集全部指标，指标名称使用云监控中的名称，如 ActiveConnection、net_rx.rate
+	Metrics        []string `yaml:"metrics"`
+	ExcludeMetrics []string `yaml:"exclude_metrics"`
+	// 是否查询实例列表补充 instance_name、ip 等标签，默认开启
+	EnrichLabels *bool `yaml:"enrich_labels"`
+	// 输出的统计值，如 Average、Maximum，为空时保持默认：slb 为 Average，nat 为 Value，eip 优先 Average
+	Statistics []string `yaml:"statistics"`
+	// 单个指标的统计值，优先于 statistics
+	MetricStatistics map[string][]string `yaml:"metric_statistics"`
+	// label（默认）使用 statistic 标签区分统计值，suffix 在指标名后加 _maximum 等后缀
+	StatisticMode string `yaml:"statistic_mode"`
+	// 云监控的统计周期，单位秒，如 60、300、900，为 0 时使用 polling.period
+	Period int `yaml:"period"`
+	// 是否使用云监控数据点的时间戳，而不是抓取时间
+	Timestamps bool `yaml:"timestamps"`
+	// 是否通过 DescribeMetricMetaList 发现并采集未内置的指标，可以用 exclude_metrics 排除
+	Discovery bool `yaml:"discovery"`
+}
+
+// NamespaceConfig 由通用 collector 采集的云监控命名空间，slb、nat、eip 之外的产品通过配置接入
+type NamespaceConfig struct {
+	// collector 名称，用于 aliyun_exporter_collector_up 等自身指标，如 ecs
+	Name string `yaml:"name"`
+	// 云监控命名空间，如 acs_ecs_dashboard
+	Namespace string `yaml:"namespace"`
+	// 指标名前缀，默认 aliyun_<name>
+	Prefix string `yaml:"prefix"`
+	// 云监控维度到标签的映射，如 instanceId: instance_id，默认为 userId 和 instanceId
+	Dimensions      map[string]string `yaml:"dimensions"`
+	CollectorConfig `yaml:",inline"`
+}
+
+type PollingConfig struct {
+	// 云监控的统计周期，单位秒，默认 60
+	Period int `yaml:"period"`
+	// 后台轮询云监控的间隔，如 60s，为 0 时每次抓取都直接调用云监控接口
+	Interval time.Duration `yaml:"interval"`
+	// DescribeMetricLast 每页返回的数据点条数，为 0 时使用接口默认值
+	PageLength int `yaml:"page_length"`
+	// slb、eip 等实例列表的缓存时间，默认 10m
+	InventoryInterval time.Duration `yaml:"inventory_interval"`
+	// 所有 collector 同时进行的云监控请求数，默认 10
+	Concurrency int `yaml:"concurrency"`
+	// 根据 Prometheus 的抓取超时计算采集超时时预留的时间，默认 500ms
+	ScrapeTimeoutMargin time.Duration `yaml:"scrape_timeout_margin"`
+}
+
+// APIConfig 阿里云接口的限流和重试
+type APIConfig struct {
+	// 每个接口、endpoint 每秒的请求数，为 0 时不限制
+	RateLimit float64 `yaml:"rate_limit"`
+	// 单独设置某个接口每秒的请求数，如 DescribeMetricLast: 10
+	RateLimits map[string]float64 `yaml:"rate_limits"`
+	// 令牌桶容量，默认 1
+	Burst int `yaml:"burst"`
+	// 限流和网络错误的重试次数，默认 3，为 -1 时不重试
+	MaxRetries int `yaml:"max_retries"`
+	// 指数退避的初始和最大等待时间，默认 200ms 和 5s
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+	// 单次请求的读取和连接超时，默认 10s 和 5s
+	Timeout        time.Duration `yaml:"timeout"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+var (
+	globalConfig = &Config{}
+	configMutex  sync.RWMutex
+)
+
+// LoadConfig 读取 yaml 配置文件，文件名为空时使用空配置。
+// 读取或校验失败时返回错误，并继续使用上一次成功加载的配置
+func LoadConfig(filename string) error {
+	c := &Config{}
+	if filename != "" {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(content, c); err != nil {
+			return err
+		}
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	globalConfig = c
+	configMutex.Unlock()
+	return nil
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]bool)
+	for _, a := range c.Accounts {
+		if a.Name == "" {
+			return fmt.Errorf("account name must not be empty")
+		}
+		if names[a.Name] {
+			return fmt.Errorf("duplicate account name %q", a.Name)
+		}
+		names[a.Name] = true
+		if (a.AccessKeyId == "") != (a.AccessKeySecret == "") {
+			return fmt.Errorf("account %q: access_key_id and access_key_secret must be set together", a.Name)
+		}
+	}
+
+	for name, collector := range c.Collectors {
+		if !contains(collectorNames, name) {
+			return fmt.Errorf("unknown collector %q, available collectors: %s", name, strings.Join(collectorNames, ", "))
+		}
+		if err := collector.validate(); err != nil {
+			return fmt.Errorf("collector %q: %w", name, err)
+		}
+	}
+
+	// 通用 collector 与内置 collector 共用 collector 标签，名称不能重复
+	namespaceNames := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	for _, ns := range c.Namespaces {
+		if ns.Name == "" || ns.Namespace == "" {
+			return fmt.Errorf("namespace name and namespace must not be empty")
+		}
+		if namespaceNames[ns.Name] || contains(collectorNames, ns.Name) {
+			return fmt.Errorf("duplicate collector name %q", ns.Name)
+		}
+		namespaceNames[ns.Name] = true
+		// 缓存时长、抓取超时等自身指标按命名空间区分
+		if namespaces[ns.Namespace] || contains(builtinNamespaces, ns.Namespace) {
+			return fmt.Errorf("namespace %q: duplicate namespace %q", ns.Name, ns.Namespace)
+		}
+		namespaces[ns.Namespace] = true
+		if err := ns.validate(); err != nil {
+			return fmt.Errorf("namespace %q: %w", ns.Name, err)
+		}
+	}
+
+	if c.Polling.Period < 0 || c.Polling.Period%60 != 0 {
+		return fmt.Errorf("polling period must be a multiple of 60, got %d", c.Polling.Period)
+	}
+	if c.Polling.PageLength < 0 {
+		return fmt.Errorf("polling page_length must not be negative, got %d", c.Polling.PageLength)
+	}
+	if c.Polling.InventoryInterval < 0 {
+		return fmt.Errorf("polling inventory_interval must not be negative, got %s", c.Polling.InventoryInterval)
+	}
+	if c.Polling.Concurrency < 0 {
+		return fmt.Errorf("polling concurrency must not be negative, got %d", c.Polling.Concurrency)
+	}
+	if err := c.API.validate(); err != nil {
+		return fmt.Errorf("api: %w", err)
+	}
+	if c.Polling.ScrapeTimeoutMargin < 0 {
+		return fmt.Errorf("polling scrape_timeout_margin must not be negative, got %s", c.Polling.ScrapeTimeoutMargin)
+	}
+	if c.Polling.Interval < 0 {
+		return fmt.Errorf("polling interval must not be negative, got %s", c.Polling.Interval)
+	}
+	// 没有地域时不会采集任何指标，collector_up 却为 1，启动时直接报错
+	for _, a := range c.accounts() {
+		if len(a.Regions) == 0 {
+			return fmt.Errorf("account %q has no regions, set --region.id or regions", a.Name)
+		}
+	}
+	return nil
+}
+
+func (c CollectorConfig) validate() error {
+	stats := append([]string{}, c.Statistics...)
+	for _, s := range c.MetricStatistics {
+		stats = append(stats, s...)
+	}
+	for _, s := range stats {
+		if !contains(statisticNames, s) {
+			return fmt.Errorf("unknown statistic %q, available statistics: %s", s, strings.Join(statisticNames, ", "))
+		}
+	}
+	if c.Period < 0 || c.Period%60 != 0 {
+		return fmt.Errorf("period must be a multiple of 60, got %d", c.Period)
+	}
+	switch c.StatisticMode {
+	case "", statisticModeLabel, statisticModeSuffix:
+	default:
+		return fmt.Errorf("unknown statistic_mode %q", c.StatisticMode)
+	}
+	return nil
+}
+
+func (c NamespaceConfig) validate() error {
+	if len(c.Metrics) == 0 && !c.Discovery {
+		return fmt.Errorf("metrics must not be empty when discovery is disabled")
+	}
+	if !model.IsValidMetricName(model.LabelValue(c.prefix())) {
+		return fmt.Errorf("invalid prefix %q", c.prefix())
+	}
+	labels := map[string]bool{"account": true, "region": true}
+	for dimension, label := range c.Dimensions {
+		if !model.LabelName(label).IsValid() {
+			return fmt.Errorf("invalid label %q for dimension %q", label, dimension)
+		}
+		if labels[label] {
+			return fmt.Errorf("duplicate label %q", label)
+		}
+		labels[label] = true
+	}
+	return c.CollectorConfig.validate()
+}
+
+func (c APIConfig) validate() error {
+	if c.RateLimit < 0 || c.Burst < 0 || c.MaxRetries < -1 || c.RetryBaseDelay < 0 || c.RetryMaxDelay < 0 || c.Timeout < 0 || c.ConnectTimeout < 0 {
+		return fmt.Errorf("rate_limit, burst, retry delays and timeouts must not be negative, max_retries must not be less than -1")
+	}
+	for api, limit := range c.RateLimits {
+		if limit < 0 {
+			return fmt.Errorf("rate limit of %s must not be negative", api)
+		}
+	}
+	return nil
+}
+
+func currentConfig() *Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return globalConfig
+}
+
+// regions 合并 --region.id 与配置文件中的地域，去重后返回
+func (c *Config) regions() []string {
+	return uniqueRegions(append(strings.Split(*regionId, ","), c.Regions...))
+}
+
+// uniqueRegions 去掉地域的空白和空值，按第一次出现的顺序去重
+func uniqueRegions(regions []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, r := range regions {
+		r = strings.TrimSpace(r)
+		if r == "" || seen[r] {
+			continue
+		}
+		seen[r] = true
+		result = append(result, r)
+	}
+	return result
+}
+
+// accounts 返回需要采集的账号列表，配置文件中没有账号时使用命令行参数中的 AccessKey，
+// 命令行参数也没有设置时通过凭证链获取
+func (c *Config) accounts() []Account {
+	defaultRegions := c.regions()
+	if len(c.Accounts) == 0 {
+		accesskeyid, _ := base64.StdEncoding.DecodeString(*AccessKeyId)
+		accesskeysecret, _ := base64.StdEncoding.DecodeString(*AccessKeySecret)
+		return []Account{{
+			Name: "default",
+			CredentialConfig: CredentialConfig{
+				AccessKeyId:     string(accesskeyid),
+				AccessKeySecret: string(accesskeysecret),
+			},
+			Regions: defaultRegions,
+		}}
+	}
+
+	result := make([]Account, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		a.Regions = uniqueRegions(a.Regions)
+		if len(a.Regions) == 0 {
+			a.Regions = defaultRegions
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// collector 返回 collector 的配置，包括 namespaces 中配置的通用 collector
+func (c *Config) collector(name string) CollectorConfig {
+	collector, ok := c.Collectors[name]
+	if !ok {
+		if ns, ok := c.namespace(name); ok {
+			return ns.CollectorConfig
+		}
+	}
+	if collector.Enabled == nil && contains(optInCollectors, name) {
+		collector.Enabled = new(bool)
+	}
+	return collector
+}
+
+func (c *Config) namespace(name string) (NamespaceConfig, bool) {
+	for _, ns := range c.Namespaces {
+		if ns.Name == name {
+			return ns, true
+		}
+	}
+	return NamespaceConfig{}, false
+}
+
+// period 返回 collector 使用的统计周期，依次取 collector 的 period、polling.period，默认 60
+func (c *Config) period(collector string) string {
+	if p := c.collector(collector).Period; p != 0 {
+		return fmt.Sprint(p)
+	}
+	if c.Polling.Period != 0 {
+		return fmt.Sprint(c.Polling.Period)
+	}
+	return "60"
+}
+
+func (c *Config) pageLength() string {
+	if c.Polling.PageLength == 0 {
+		return ""
+	}
+	return fmt.Sprint(c.Polling.PageLength)
+}
+
+func (c *Config) pollingInterval() time.Duration {
+	return c.Polling.Interval
+}
+
+func (c *Config) inventoryInterval() time.Duration {
+	if c.Polling.InventoryInterval == 0 {
+		return 10 * time.Minute
+	}
+	return c.Polling.InventoryInterval
+}
+
+func (c *Config) concurrency() int {
+	if c.Polling.Concurrency == 0 {
+		return 10
+	}
+	return c.Polling.Concurrency
+}
+
+func (c APIConfig) rateLimit(api string) float64 {
+	if limit, ok := c.RateLimits[api]; ok {
+		return limit
+	}
+	return c.RateLimit
+}
+
+func (c APIConfig) burst() int {
+	if c.Burst == 0 {
+		return 1
+	}
+	return c.Burst
+}
+
+func (c APIConfig) maxRetries() int {
+	switch c.MaxRetries {
+	case 0:
+		return 3
+	case -1:
+		return 0
+	}
+	return c.MaxRetries
+}
+
+func (c APIConfig) retryBaseDelay() time.Duration {
+	if c.RetryBaseDelay == 0 {
+		return 200 * time.Millisecond
+	}
+	return c.RetryBaseDelay
+}
+
+func (c APIConfig) retryMaxDelay() time.Duration {
+	if c.RetryMaxDelay == 0 {
+		return 5 * time.Second
+	}
+	return c.RetryMaxDelay
+}
+
+func (c APIConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return c.Timeout
+}
+
+func (c APIConfig) connectTimeout() time.Duration {
+	if c.ConnectTimeout == 0 {
+		return 5 * time.Second
+	}
+	return c.ConnectTimeout
+}
+
+func (c *Config) scrapeTimeoutMargin() time.Duration {
+	if c.Polling.ScrapeTimeoutMargin == 0 {
+		return 500 * time.Millisecond
+	}
+	return c.Polling.ScrapeTimeoutMargin
+}
+
+func (c NamespaceConfig) prefix() string {
+	if c.Prefix == "" {
+		return "aliyun_" + c.Name
+	}
+	return c.Prefix
+}
+
+// labels 返回按标签名排序的维度和对应的标签，标签前后加上 account 和 region
+func (c NamespaceConfig) labels() (dimensions []string, labels []string) {
+	mapping := c.Dimensions
+	if len(mapping) == 0 {
+		mapping = map[string]string{"userId": "user_id", "instanceId": "instance_id"}
+	}
+	for dimension := range mapping {
+		dimensions = append(dimensions, dimension)
+	}
+	sort.Slice(dimensions, func(i, j int) bool { return mapping[dimensions[i]] < mapping[dimensions[j]] })
+
+	labels = append(labels, "account")
+	for _, dimension := range dimensions {
+		labels = append(labels, mapping[dimension])
+	}
+	labels = append(labels, "region")
+	return dimensions, labels
+}
+
+func (c CollectorConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+func (c CollectorConfig) enrichLabels() bool {
+	return c.EnrichLabels == nil || *c.EnrichLabels
+}
+
+func (c CollectorConfig) metricEnabled(metricName string) bool {
+	if len(c.Metrics) > 0 && !contains(c.Metrics, metricName) {
+		return false
+	}
+	return !contains(c.ExcludeMetrics, metricName)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
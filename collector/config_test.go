@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestConfig(t *testing.T, content string) error {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(filename)
+}
+
+// configTest 加载 content，err 为空时期望加载成功，否则期望错误中包含 err
+type configTest struct {
+	name    string
+	content string
+	err     string
+}
+
+func runConfigTests(t *testing.T, tests []configTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadTestConfig(t, tt.content)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRegions(t *testing.T) {
+	tests := []configTest{
+		{
+			name:    "no regions",
+			content: "collectors: {}\n",
+			err:     `account "default" has no regions`,
+		},
+		{
+			name:    "account without regions",
+			content: "accounts:\n  - name: a\n    regions: [cn-hangzhou]\n  - name: b\n",
+			err:     `account "b" has no regions`,
+		},
+		{
+			name:    "global regions",
+			content: "regions: [cn-hangzhou]\naccounts:\n  - name: a\n",
+		},
+	}
+	runConfigTests(t, tests)
+}
+
+func TestAccountRegions(t *testing.T) {
+	if err := loadTestConfig(t, "regions: [cn-shanghai]\naccounts:\n  - name: a\n    regions: [cn-hangzhou, ' cn-hangzhou', '']\n  - name: b\n    regions: ['']\n"); err != nil {
+		t.Fatal(err)
+	}
+	accounts := currentConfig().accounts()
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+	if got := strings.Join(accounts[0].Regions, ","); got != "cn-hangzhou" {
+		t.Errorf("account a: expected regions cn-hangzhou, got %s", got)
+	}
+	if got := strings.Join(accounts[1].Regions, ","); got != "cn-shanghai" {
+		t.Errorf("account b: expected default regions cn-shanghai, got %s", got)
+	}
+}
+
+func TestNamespaceNames(t *testing.T) {
+	tests := []configTest{
+		{
+			name:    "same name as an account",
+			content: "regions: [cn-hangzhou]\naccounts:\n  - name: ecs\nnamespaces:\n  - name: ecs\n    namespace: acs_ecs_dashboard\n    metrics: [CPUUtilization]\n",
+		},
+		{
+			name:    "same name as a builtin collector",
+			content: "regions: [cn-hangzhou]\nnamespaces:\n  - name: slb\n    namespace: acs_ecs_dashboard\n    metrics: [CPUUtilization]\n",
+			err:     `duplicate collector name "slb"`,
+		},
+		{
+			name:    "duplicate namespace name",
+			content: "regions: [cn-hangzhou]\nnamespaces:\n  - name: ecs\n    namespace: acs_ecs_dashboard\n    metrics: [CPUUtilization]\n  - name: ecs\n    namespace: acs_rds_dashboard\n    metrics: [CpuUsage]\n",
+			err:     `duplicate collector name "ecs"`,
+		},
+		{
+			name:    "duplicate namespace",
+			content: "regions: [cn-hangzhou]\nnamespaces:\n  - name: ecs\n    namespace: acs_ecs_dashboard\n    metrics: [CPUUtilization]\n  - name: ecs_disk\n    namespace: acs_ecs_dashboard\n    metrics: [diskusage_utilization]\n",
+			err:     `duplicate namespace "acs_ecs_dashboard"`,
+		},
+		{
+			name:    "builtin namespace",
+			content: "regions: [cn-hangzhou]\nnamespaces:\n  - name: lb\n    namespace: acs_slb_dashboard\n    metrics: [Qps]\n",
+			err:     `duplicate namespace "acs_slb_dashboard"`,
+		},
+	}
+	runConfigTests(t, tests)
+}
+
+func TestCollectorEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	conf := &Config{Collectors: map[string]CollectorConfig{
+		"nat":         {Enabled: &disabled},
+		"slb_backend": {Enabled: &enabled},
+		"eip":         {Metrics: []string{"net_rx.rate"}},
+	}}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "slb", want: true},
+		{name: "nat", want: false},
+		{name: "eip", want: true},
+		// 会为每个实例调用接口的 collector 需要显式开启
+		{name: "slb_inventory", want: false},
+		{name: "slb_backend", want: true},
+	}
+	for _, tt := range tests {
+		if got := conf.collector(tt.name).enabled(); got != tt.want {
+			t.Errorf("collector %q: expected enabled %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
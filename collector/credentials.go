@@ -0,0 +1,471 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/client"
+	util "github.com/alibabacloud-go/tea-utils/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/go-kit/log/level"
+)
+
+// 临时凭证在过期前多久刷新
+const credentialRefreshWindow = 5 * time.Minute
+
+var errNoCredentials = errors.New("no credentials found")
+
+// CredentialConfig 账号的认证方式，都为空时依次尝试环境变量、凭证文件和 ECS RAM 角色
+type CredentialConfig struct {
+	AccessKeyId     string `yaml:"access_key_id"`
+	AccessKeySecret string `yaml:"access_key_secret"`
+	// ~/.aliyun/config.json 中的 profile 名称
+	Profile string `yaml:"profile"`
+	// ECS 实例绑定的 RAM 角色名称
+	EcsRamRole string `yaml:"ecs_ram_role"`
+	// 使用上面获取的凭证通过 STS AssumeRole 扮演的角色
+	RoleArn         string `yaml:"role_arn"`
+	RoleSessionName string `yaml:"role_session_name"`
+	ExternalId      string `yaml:"external_id"`
+	DurationSeconds int    `yaml:"duration_seconds"`
+}
+
+type credentials struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string
+	// 零值表示长期有效
+	Expiration time.Time
+}
+
+type credentialProvider interface {
+	retrieve() (*credentials, error)
+}
+
+type staticProvider struct {
+	accessKeyId     string
+	accessKeySecret string
+	securityToken   string
+}
+
+func (p staticProvider) retrieve() (*credentials, error) {
+	if p.accessKeyId == "" || p.accessKeySecret == "" {
+		return nil, errNoCredentials
+	}
+	return &credentials{
+		AccessKeyId:     p.accessKeyId,
+		AccessKeySecret: p.accessKeySecret,
+		SecurityToken:   p.securityToken,
+	}, nil
+}
+
+// envProvider 从 ALIBABA_CLOUD_ACCESS_KEY_ID 等环境变量读取凭证
+type envProvider struct{}
+
+func (envProvider) retrieve() (*credentials, error) {
+	return staticProvider{
+		accessKeyId:     firstEnv("ALIBABA_CLOUD_ACCESS_KEY_ID", "ALICLOUD_ACCESS_KEY"),
+		accessKeySecret: firstEnv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "ALICLOUD_SECRET_KEY"),
+		securityToken:   firstEnv("ALIBABA_CLOUD_SECURITY_TOKEN", "ALICLOUD_SECURITY_TOKEN"),
+	}.retrieve()
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// profileProvider 读取阿里云 CLI 的 ~/.aliyun/config.json
+type profileProvider struct {
+	name string
+}
+
+type cliProfile struct {
+	Name            string `json:"name"`
+	Mode            string `json:"mode"`
+	AccessKeyId     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	StsToken        string `json:"sts_token"`
+	RamRoleName     string `json:"ram_role_name"`
+	RamRoleArn      string `json:"ram_role_arn"`
+	RamSessionName  string `json:"ram_session_name"`
+	ExpiredSeconds  int    `json:"expired_seconds"`
+}
+
+func (p profileProvider) retrieve() (*credentials, error) {
+	path := *credentialsFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".aliyun", "config.json")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Current  string       `json:"current"`
+		Profiles []cliProfile `json:"profiles"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	name := p.name
+	if name == "" {
+		name = firstEnv("ALIBABA_CLOUD_PROFILE")
+	}
+	if name == "" {
+		name = file.Current
+	}
+	for _, profile := range file.Profiles {
+		if profile.Name != name {
+			continue
+		}
+		source := staticProvider{accessKeyId: profile.AccessKeyId, accessKeySecret: profile.AccessKeySecret}
+		switch profile.Mode {
+		case "AK", "":
+			return source.retrieve()
+		case "StsToken":
+			source.securityToken = profile.StsToken
+			return source.retrieve()
+		case "EcsRamRole":
+			return ecsRAMRoleProvider{roleName: profile.RamRoleName}.retrieve()
+		case "RamRoleArn":
+			return (&assumeRoleProvider{
+				source:          source,
+				roleArn:         profile.RamRoleArn,
+				sessionName:     profile.RamSessionName,
+				durationSeconds: profile.ExpiredSeconds,
+			}).retrieve()
+		default:
+			return nil, fmt.Errorf("profile %q: unsupported mode %q", name, profile.Mode)
+		}
+	}
+	return nil, fmt.Errorf("profile %q not found in %s", name, path)
+}
+
+// ecsRAMRoleProvider 通过 ECS 元数据服务获取实例 RAM 角色的临时凭证
+type ecsRAMRoleProvider struct {
+	roleName string
+}
+
+var metadataClient = &http.Client{Timeout: 5 * time.Second}
+
+func metadataGet(path string) ([]byte, error) {
+	url := strings.TrimSuffix(*metadataEndpoint, "/") + "/latest/meta-data/ram/security-credentials/" + path
+	resp, err := metadataClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata %s returned %d", url, resp.StatusCode)
+	}
+	return body, nil
+}
+
+func (p ecsRAMRoleProvider) retrieve() (*credentials, error) {
+	roleName := p.roleName
+	if roleName == "" {
+		body, err := metadataGet("")
+		if err != nil {
+			return nil, err
+		}
+		roleName = strings.TrimSpace(strings.SplitN(string(body), "\n", 2)[0])
+		if roleName == "" {
+			return nil, errors.New("no ram role attached to the ecs instance")
+		}
+	}
+
+	body, err := metadataGet(roleName)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Code            string
+		AccessKeyId     string
+		AccessKeySecret string
+		SecurityToken   string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Code != "Success" {
+		return nil, fmt.Errorf("get credentials of ram role %q: code %s", roleName, result.Code)
+	}
+	return &credentials{
+		AccessKeyId:     result.AccessKeyId,
+		AccessKeySecret: result.AccessKeySecret,
+		SecurityToken:   result.SecurityToken,
+		Expiration:      result.Expiration,
+	}, nil
+}
+
+// assumeRoleProvider 使用 source 的凭证调用 STS AssumeRole
+type assumeRoleProvider struct {
+	source          credentialProvider
+	roleArn         string
+	sessionName     string
+	externalId      string
+	durationSeconds int
+}
+
+// stsProtocolAndHost 解析 --sts.endpoint，支持 http://127.0.0.1:8080 这样带协议的地址，默认 https
+func stsProtocolAndHost() (string, string) {
+	e := *stsEndpoint
+	if i := strings.Index(e, "://"); i >= 0 {
+		return strings.ToUpper(e[:i]), strings.TrimSuffix(e[i+3:], "/")
+	}
+	return "HTTPS", e
+}
+
+func (p *assumeRoleProvider) retrieve() (*credentials, error) {
+	source, err := p.source.retrieve()
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, host := stsProtocolAndHost()
+	config := &openapi.Config{
+		AccessKeyId:     tea.String(source.AccessKeyId),
+		AccessKeySecret: tea.String(source.AccessKeySecret),
+		Endpoint:        tea.String(host),
+		Protocol:        tea.String(protocol),
+	}
+	if source.SecurityToken != "" {
+		config.SecurityToken = tea.String(source.SecurityToken)
+	}
+	client, err := openapi.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionName := p.sessionName
+	if sessionName == "" {
+		sessionName = "aliyun-exporter"
+	}
+	durationSeconds := p.durationSeconds
+	if durationSeconds == 0 {
+		durationSeconds = 3600
+	}
+	query := map[string]*string{
+		"RoleArn":         tea.String(p.roleArn),
+		"RoleSessionName": tea.String(sessionName),
+		"DurationSeconds": tea.String(strconv.Itoa(durationSeconds)),
+	}
+	if p.externalId != "" {
+		query["ExternalId"] = tea.String(p.externalId)
+	}
+	response, err := client.DoRPCRequest(tea.String("AssumeRole"), tea.String("2015-04-01"), tea.String(protocol), tea.String("POST"),
+		tea.String("AK"), tea.String("json"), &openapi.OpenApiRequest{Query: query}, &util.RuntimeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(response["body"])
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyId     string
+			AccessKeySecret string
+			SecurityToken   string
+			Expiration      time.Time
+		}
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+	if result.Credentials.AccessKeyId == "" {
+		return nil, fmt.Errorf("assume role %s: empty credentials in response", p.roleArn)
+	}
+	return &credentials{
+		AccessKeyId:     result.Credentials.AccessKeyId,
+		AccessKeySecret: result.Credentials.AccessKeySecret,
+		SecurityToken:   result.Credentials.SecurityToken,
+		Expiration:      result.Credentials.Expiration,
+	}, nil
+}
+
+// chainProvider 按顺序尝试，返回第一个成功获取的凭证
+type chainProvider []credentialProvider
+
+func (c chainProvider) retrieve() (*credentials, error) {
+	var errs []string
+	for _, p := range c {
+		creds, err := p.retrieve()
+		if err == nil {
+			return creds, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("%w: %s", errNoCredentials, strings.Join(errs, "; "))
+}
+
+func newCredentialProvider(c CredentialConfig) credentialProvider {
+	var provider credentialProvider
+	switch {
+	case c.AccessKeyId != "":
+		provider = staticProvider{accessKeyId: c.AccessKeyId, accessKeySecret: c.AccessKeySecret}
+	case c.EcsRamRole != "":
+		provider = ecsRAMRoleProvider{roleName: c.EcsRamRole}
+	case c.Profile != "":
+		provider = profileProvider{name: c.Profile}
+	default:
+		provider = chainProvider{envProvider{}, profileProvider{}, ecsRAMRoleProvider{}}
+	}
+	if c.RoleArn != "" {
+		provider = &assumeRoleProvider{
+			source:          provider,
+			roleArn:         c.RoleArn,
+			sessionName:     c.RoleSessionName,
+			externalId:      c.ExternalId,
+			durationSeconds: c.DurationSeconds,
+		}
+	}
+	return provider
+}
+
+// 凭证获取失败后的重试间隔，每次失败翻倍，期间直接返回上次的错误
+const (
+	credentialRetryBaseDelay = 5 * time.Second
+	credentialRetryMaxDelay  = 5 * time.Minute
+)
+
+// fresh 凭证长期有效或者距离过期还有 credentialRefreshWindow 以上
+func (c *credentials) fresh(now time.Time) bool {
+	return c.Expiration.IsZero() || c.Expiration.Sub(now) > credentialRefreshWindow
+}
+
+func (c *credentials) valid(now time.Time) bool {
+	return c.Expiration.IsZero() || now.Before(c.Expiration)
+}
+
+// credentials 实现了 openapi.Config 中的 credential.Credential 接口，
+// 作为一份不可变的凭证绑定到单次调用使用的 client 上，见 bindCredential
+func (c *credentials) GetAccessKeyId() (*string, error) {
+	return tea.String(c.AccessKeyId), nil
+}
+
+func (c *credentials) GetAccessKeySecret() (*string, error) {
+	return tea.String(c.AccessKeySecret), nil
+}
+
+func (c *credentials) GetSecurityToken() (*string, error) {
+	return tea.String(c.SecurityToken), nil
+}
+
+func (c *credentials) GetBearerToken() *string {
+	return tea.String("")
+}
+
+func (c *credentials) GetType() *string {
+	if c.SecurityToken != "" {
+		return tea.String("sts")
+	}
+	return tea.String("access_key")
+}
+
+// cachedCredential 缓存 provider 获取的凭证，在临时凭证过期前刷新。
+// 凭证整体替换，读取时不会拿到不同凭证的 AccessKeyId 和 AccessKeySecret
+type cachedCredential struct {
+	provider credentialProvider
+	// *credentials
+	current atomic.Value
+
+	// 同一时间只有一个 goroutine 调用 provider
+	mutex    sync.Mutex
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+func (c *cachedCredential) load() *credentials {
+	creds, _ := c.current.Load().(*credentials)
+	return creds
+}
+
+// get 返回当前的凭证，需要刷新时调用 provider；
+// 获取失败后按指数退避重试，退避期间旧凭证没过期就继续使用，否则返回上次的错误
+func (c *cachedCredential) get() (*credentials, error) {
+	if creds := c.load(); creds != nil && creds.fresh(time.Now()) {
+		return creds, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	creds := c.load()
+	if creds != nil && creds.fresh(now) {
+		return creds, nil
+	}
+	if now.Before(c.retryAt) {
+		if creds != nil && creds.valid(now) {
+			return creds, nil
+		}
+		return nil, c.err
+	}
+
+	refreshed, err := c.provider.retrieve()
+	if err != nil {
+		delay := credentialRetryBaseDelay << c.failures
+		if delay <= 0 || delay > credentialRetryMaxDelay {
+			delay = credentialRetryMaxDelay
+		}
+		c.err = err
+		c.retryAt = now.Add(delay)
+		c.failures++
+		if creds != nil && creds.valid(now) {
+			level.Warn(logger).Log("msg", "Failed to refresh credentials, using cached ones", "err", err, "retry", delay)
+			return creds, nil
+		}
+		return nil, err
+	}
+	c.err = nil
+	c.failures = 0
+	c.retryAt = time.Time{}
+	c.current.Store(refreshed)
+	return refreshed, nil
+}
+
+var (
+	credentialCache      = make(map[CredentialConfig]*cachedCredential)
+	credentialCacheMutex sync.Mutex
+)
+
+// accountCredential 相同认证配置的账号共用一个凭证缓存，避免每次请求都访问元数据服务或 STS
+func accountCredential(account Account) *cachedCredential {
+	credentialCacheMutex.Lock()
+	defer credentialCacheMutex.Unlock()
+
+	c, ok := credentialCache[account.CredentialConfig]
+	if !ok {
+		c = &cachedCredential{provider: newCredentialProvider(account.CredentialConfig)}
+		credentialCache[account.CredentialConfig] = c
+	}
+	return c
+}
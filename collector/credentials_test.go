@@ -0,0 +1,325 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setFlag(t *testing.T, flag *string, value string) {
+	t.Helper()
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+// clearCredentialEnv 清空环境变量和凭证文件，避免测试读到运行环境中的凭证
+func clearCredentialEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"ALIBABA_CLOUD_ACCESS_KEY_ID", "ALICLOUD_ACCESS_KEY",
+		"ALIBABA_CLOUD_ACCESS_KEY_SECRET", "ALICLOUD_SECRET_KEY",
+		"ALIBABA_CLOUD_SECURITY_TOKEN", "ALICLOUD_SECURITY_TOKEN",
+		"ALIBABA_CLOUD_PROFILE",
+	} {
+		t.Setenv(name, "")
+	}
+	setFlag(t, credentialsFile, filepath.Join(t.TempDir(), "missing.json"))
+	setFlag(t, metadataEndpoint, "http://127.0.0.1:1")
+}
+
+func writeProfiles(t *testing.T, current string, profiles ...cliProfile) {
+	t.Helper()
+	content, err := json.Marshal(map[string]interface{}{"current": current, "profiles": profiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, credentialsFile, path)
+}
+
+// newMetadataServer 模拟 ECS 元数据服务，实例绑定了名为 role 的 RAM 角色
+func newMetadataServer(t *testing.T, expiration time.Time) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/meta-data/ram/security-credentials/":
+			fmt.Fprint(w, "role\n")
+		case "/latest/meta-data/ram/security-credentials/role":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Code":            "Success",
+				"AccessKeyId":     "STS.ecs",
+				"AccessKeySecret": "ecs-secret",
+				"SecurityToken":   "ecs-token",
+				"Expiration":      expiration.UTC().Format(time.RFC3339),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	setFlag(t, metadataEndpoint, server.URL)
+	return server
+}
+
+type assumeRoleRequest struct {
+	accessKeyId string
+	roleArn     string
+	externalId  string
+}
+
+// newSTSServer 模拟 STS，记录收到的 AssumeRole 请求
+func newSTSServer(t *testing.T) *[]assumeRoleRequest {
+	t.Helper()
+	var (
+		mutex    sync.Mutex
+		requests []assumeRoleRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("Action") != "AssumeRole" {
+			http.Error(w, `{"Code":"InvalidAction"}`, http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		requests = append(requests, assumeRoleRequest{
+			accessKeyId: query.Get("AccessKeyId"),
+			roleArn:     query.Get("RoleArn"),
+			externalId:  query.Get("ExternalId"),
+		})
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"RequestId": "test",
+			"Credentials": map[string]string{
+				"AccessKeyId":     "STS.assumed",
+				"AccessKeySecret": "assumed-secret",
+				"SecurityToken":   "assumed-token",
+				"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	setFlag(t, stsEndpoint, server.URL)
+	return &requests
+}
+
+func TestEnvProvider(t *testing.T) {
+	clearCredentialEnv(t)
+	if _, err := (envProvider{}).retrieve(); !errors.Is(err, errNoCredentials) {
+		t.Fatalf("expected errNoCredentials, got %v", err)
+	}
+
+	t.Setenv("ALICLOUD_ACCESS_KEY", "env-id")
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "env-secret")
+	t.Setenv("ALIBABA_CLOUD_SECURITY_TOKEN", "env-token")
+	creds, err := (envProvider{}).retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyId != "env-id" || creds.AccessKeySecret != "env-secret" || creds.SecurityToken != "env-token" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+}
+
+func TestProfileProvider(t *testing.T) {
+	clearCredentialEnv(t)
+	newMetadataServer(t, time.Now().Add(time.Hour))
+	requests := newSTSServer(t)
+	writeProfiles(t, "ak",
+		cliProfile{Name: "ak", Mode: "AK", AccessKeyId: "ak-id", AccessKeySecret: "ak-secret"},
+		cliProfile{Name: "sts", Mode: "StsToken", AccessKeyId: "sts-id", AccessKeySecret: "sts-secret", StsToken: "sts-token"},
+		cliProfile{Name: "ecs", Mode: "EcsRamRole", RamRoleName: "role"},
+		cliProfile{Name: "arn", Mode: "RamRoleArn", AccessKeyId: "arn-id", AccessKeySecret: "arn-secret", RamRoleArn: "acs:ram::1:role/test"},
+		cliProfile{Name: "bad", Mode: "ChainableRamRoleArn"},
+	)
+
+	tests := []struct {
+		profile     string
+		env         string
+		accessKeyId string
+		token       string
+		err         bool
+	}{
+		{profile: "", accessKeyId: "ak-id"},
+		{profile: "", env: "sts", accessKeyId: "sts-id", token: "sts-token"},
+		{profile: "ecs", accessKeyId: "STS.ecs", token: "ecs-token"},
+		{profile: "arn", accessKeyId: "STS.assumed", token: "assumed-token"},
+		{profile: "bad", err: true},
+		{profile: "missing", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile+tt.env, func(t *testing.T) {
+			t.Setenv("ALIBABA_CLOUD_PROFILE", tt.env)
+			creds, err := profileProvider{name: tt.profile}.retrieve()
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", creds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.AccessKeyId != tt.accessKeyId || creds.SecurityToken != tt.token {
+				t.Errorf("unexpected credentials %+v", creds)
+			}
+		})
+	}
+	if len(*requests) != 1 || (*requests)[0].accessKeyId != "arn-id" {
+		t.Errorf("expected one AssumeRole request signed by arn-id, got %+v", *requests)
+	}
+}
+
+func TestECSRAMRoleProvider(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	newMetadataServer(t, expiration)
+
+	for _, roleName := range []string{"", "role"} {
+		creds, err := ecsRAMRoleProvider{roleName: roleName}.retrieve()
+		if err != nil {
+			t.Fatalf("role %q: %v", roleName, err)
+		}
+		if creds.AccessKeyId != "STS.ecs" || creds.SecurityToken != "ecs-token" || !creds.Expiration.Equal(expiration) {
+			t.Errorf("role %q: unexpected credentials %+v", roleName, creds)
+		}
+	}
+	if _, err := (ecsRAMRoleProvider{roleName: "other"}).retrieve(); err == nil {
+		t.Error("expected error for a role not attached to the instance")
+	}
+}
+
+func TestAssumeRoleProvider(t *testing.T) {
+	requests := newSTSServer(t)
+	provider := newCredentialProvider(CredentialConfig{
+		AccessKeyId:     "source-id",
+		AccessKeySecret: "source-secret",
+		RoleArn:         "acs:ram::1:role/test",
+		ExternalId:      "external",
+	})
+	creds, err := provider.retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyId != "STS.assumed" || creds.SecurityToken != "assumed-token" || creds.Expiration.IsZero() {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+	want := assumeRoleRequest{accessKeyId: "source-id", roleArn: "acs:ram::1:role/test", externalId: "external"}
+	if len(*requests) != 1 || (*requests)[0] != want {
+		t.Errorf("expected request %+v, got %+v", want, *requests)
+	}
+}
+
+func TestChainProvider(t *testing.T) {
+	clearCredentialEnv(t)
+	chain := newCredentialProvider(CredentialConfig{})
+	if _, err := chain.retrieve(); !errors.Is(err, errNoCredentials) {
+		t.Fatalf("expected errNoCredentials, got %v", err)
+	}
+
+	// 环境变量和凭证文件都没有时使用 ECS RAM 角色
+	newMetadataServer(t, time.Now().Add(time.Hour))
+	creds, err := chain.retrieve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyId != "STS.ecs" {
+		t.Errorf("expected ecs ram role credentials, got %+v", creds)
+	}
+
+	// 凭证文件优先于 ECS RAM 角色
+	writeProfiles(t, "default", cliProfile{Name: "default", AccessKeyId: "profile-id", AccessKeySecret: "profile-secret"})
+	if creds, err = chain.retrieve(); err != nil || creds.AccessKeyId != "profile-id" {
+		t.Errorf("expected profile credentials, got %+v, %v", creds, err)
+	}
+
+	// 环境变量优先于凭证文件
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "env-id")
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "env-secret")
+	if creds, err = chain.retrieve(); err != nil || creds.AccessKeyId != "env-id" {
+		t.Errorf("expected env credentials, got %+v, %v", creds, err)
+	}
+}
+
+type fakeProvider struct {
+	mutex sync.Mutex
+	calls int
+	creds []*credentials
+	err   error
+}
+
+func (p *fakeProvider) retrieve() (*credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	creds := p.creds[0]
+	if len(p.creds) > 1 {
+		p.creds = p.creds[1:]
+	}
+	return creds, nil
+}
+
+func TestCachedCredentialRefresh(t *testing.T) {
+	provider := &fakeProvider{creds: []*credentials{
+		{AccessKeyId: "first", AccessKeySecret: "first-secret", Expiration: time.Now().Add(credentialRefreshWindow / 2)},
+		{AccessKeyId: "second", AccessKeySecret: "second-secret", Expiration: time.Now().Add(time.Hour)},
+	}}
+	c := &cachedCredential{provider: provider}
+
+	// 第一份凭证已经进入刷新窗口，下一次获取时刷新
+	for _, want := range []string{"first", "second", "second"} {
+		creds, err := c.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.AccessKeyId != want || creds.AccessKeySecret != want+"-secret" {
+			t.Errorf("expected %s credentials, got %+v", want, creds)
+		}
+	}
+	if provider.calls != 2 {
+		t.Errorf("expected 2 retrieve calls, got %d", provider.calls)
+	}
+}
+
+func TestCachedCredentialFailure(t *testing.T) {
+	provider := &fakeProvider{err: errNoCredentials}
+	c := &cachedCredential{provider: provider}
+
+	// 退避期间不再调用 provider，直接返回上次的错误
+	for i := 0; i < 3; i++ {
+		if _, err := c.get(); !errors.Is(err, errNoCredentials) {
+			t.Fatalf("expected errNoCredentials, got %v", err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("expected 1 retrieve call during backoff, got %d", provider.calls)
+	}
+
+	// 退避结束后重试，刷新失败时继续使用没过期的旧凭证
+	old := &credentials{AccessKeyId: "old", Expiration: time.Now().Add(time.Minute)}
+	c.current.Store(old)
+	c.retryAt = time.Time{}
+	creds, err := c.get()
+	if err != nil || creds != old {
+		t.Errorf("expected cached credentials, got %+v, %v", creds, err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("expected 2 retrieve calls, got %d", provider.calls)
+	}
+	if delay := time.Until(c.retryAt); delay <= credentialRetryBaseDelay {
+		t.Errorf("expected backoff to grow after the second failure, got %s", delay)
+	}
+}
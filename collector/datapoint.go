@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode"
+)
+
+// datapoint 云监控返回的一个数据点。云监控中维度字段以小写字母开头，如 userId、instanceId、port，
+// 统计值以大写字母开头，如 Average、Maximum、Value
+type datapoint struct {
+	// 毫秒，0 表示数据点中没有时间戳
+	timestamp  int64
+	dimensions map[string]string
+	values     map[string]float64
+}
+
+// dimension 返回维度的值，缺失时返回空字符串
+func (d datapoint) dimension(name string) string {
+	return d.dimensions[name]
+}
+
+func (d datapoint) value(statistic string) (float64, bool) {
+	v, ok := d.values[statistic]
+	return v, ok
+}
+
+// decodeDatapoints 解析 DescribeMetricLast 返回的 Datapoints，
+// 不是对象或者没有任何统计值的数据点会被跳过，skipped 为跳过的个数
+func decodeDatapoints(raw string) (datapoints []datapoint, skipped int, err error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, 0, err
+	}
+	for _, item := range items {
+		d, ok := decodeDatapoint(item)
+		if !ok {
+			skipped++
+			continue
+		}
+		datapoints = append(datapoints, d)
+	}
+	return datapoints, skipped, nil
+}
+
+func decodeDatapoint(raw json.RawMessage) (datapoint, bool) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil || fields == nil {
+		return datapoint{}, false
+	}
+
+	d := datapoint{
+		dimensions: make(map[string]string),
+		values:     make(map[string]float64),
+	}
+	for key, v := range fields {
+		if key == "" {
+			continue
+		}
+		switch {
+		case key == "timestamp":
+			if f, ok := toFloat(v); ok {
+				d.timestamp = int64(f)
+			}
+		case unicode.IsUpper([]rune(key)[0]):
+			if f, ok := toFloat(v); ok {
+				d.values[key] = f
+			}
+		default:
+			if s, ok := toString(v); ok {
+				d.dimensions[key] = s
+			}
+		}
+	}
+	return d, len(d.values) > 0
+}
+
+// toFloat 兼容数字和数字字符串，null 等其它类型返回 false
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toString 兼容字符串、数字和布尔值，如数字类型的 port，null 和对象返回 false
+func toString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeDatapoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		datapoints []datapoint
+		skipped    int
+		err        bool
+	}{
+		{
+			name: "numeric values",
+			raw:  `[{"timestamp":1666000000000,"userId":"1","instanceId":"lb-1","port":"80","Average":1.5,"Maximum":3}]`,
+			datapoints: []datapoint{{
+				timestamp:  1666000000000,
+				dimensions: map[string]string{"userId": "1", "instanceId": "lb-1", "port": "80"},
+				values:     map[string]float64{"Average": 1.5, "Maximum": 3},
+			}},
+		},
+		{
+			name: "numeric port and string values",
+			raw:  `[{"instanceId":"lb-1","port":443,"Average":"2.5","Value":"7"}]`,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{"instanceId": "lb-1", "port": "443"},
+				values:     map[string]float64{"Average": 2.5, "Value": 7},
+			}},
+		},
+		{
+			name: "missing dimensions and timestamp",
+			raw:  `[{"Value":1}]`,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{},
+				values:     map[string]float64{"Value": 1},
+			}},
+		},
+		{
+			name: "nulls and invalid values",
+			raw:  `[{"instanceId":null,"port":null,"timestamp":null,"Average":null,"Maximum":"n/a","Minimum":0,"vip":{"a":1},"":1}]`,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{},
+				values:     map[string]float64{"Minimum": 0},
+			}},
+		},
+		{
+			name: "boolean dimension",
+			raw:  `[{"instanceId":"lb-1","isp":true,"Sum":4}]`,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{"instanceId": "lb-1", "isp": "true"},
+				values:     map[string]float64{"Sum": 4},
+			}},
+		},
+		{
+			name:    "datapoint without statistic value",
+			raw:     `[{"instanceId":"lb-1","timestamp":1666000000000},{"instanceId":"lb-2","Average":null},{"instanceId":"lb-3","Average":1}]`,
+			skipped: 2,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{"instanceId": "lb-3"},
+				values:     map[string]float64{"Average": 1},
+			}},
+		},
+		{
+			name:    "non-object datapoints",
+			raw:     `[null,1,"a",[],{"Value":2}]`,
+			skipped: 4,
+			datapoints: []datapoint{{
+				dimensions: map[string]string{},
+				values:     map[string]float64{"Value": 2},
+			}},
+		},
+		{
+			name: "empty array",
+			raw:  `[]`,
+		},
+		{
+			name: "non-array object",
+			raw:  `{"Average":1}`,
+			err:  true,
+		},
+		{
+			name: "non-array string",
+			raw:  `"[]"`,
+			err:  true,
+		},
+		{
+			name: "invalid json",
+			raw:  `[{"Average":1}`,
+			err:  true,
+		},
+		{
+			name: "empty",
+			raw:  ``,
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			datapoints, skipped, err := decodeDatapoints(tt.raw)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", datapoints)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped != tt.skipped {
+				t.Errorf("expected %d skipped, got %d", tt.skipped, skipped)
+			}
+			if !reflect.DeepEqual(datapoints, tt.datapoints) {
+				t.Errorf("expected %+v, got %+v", tt.datapoints, datapoints)
+			}
+		})
+	}
+}
+
+func TestDatapointAccessors(t *testing.T) {
+	d := datapoint{
+		dimensions: map[string]string{"instanceId": "lb-1"},
+		values:     map[string]float64{"Average": 0},
+	}
+	if got := d.dimension("instanceId"); got != "lb-1" {
+		t.Errorf("expected lb-1, got %q", got)
+	}
+	if got := d.dimension("port"); got != "" {
+		t.Errorf("expected empty port, got %q", got)
+	}
+	if v, ok := d.value("Average"); !ok || v != 0 {
+		t.Errorf("expected Average 0, got %v, %v", v, ok)
+	}
+	if _, ok := d.value("Maximum"); ok {
+		t.Error("expected missing Maximum")
+	}
+}
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	cms20190101 "github.com/alibabacloud-go/cms-20190101/v2/client"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metricMeta = cms20190101.DescribeMetricMetaListResponseBodyResourcesResource
+
+// discoveredMetric 通过 DescribeMetricMetaList 发现的指标
+type discoveredMetric struct {
+	name string
+	desc *prometheus.Desc
+	// 云监控元数据中该指标支持的统计值
+	statistics []string
+}
+
+var discoveredDescs sync.Map
+
+// discoverMetrics 返回 namespace 下除 known 以外的指标，指标名为 prefix 加上云监控指标名的蛇形命名，
+// 元数据与实例列表一样按 inventory_interval 缓存
+func discoverMetrics(ctx context.Context, conf *Config, account Account, region string, namespace string,
+	prefix string, labels []string, known map[string]bool) ([]discoveredMetric, error) {
+	value, err := inventory.get("meta/"+account.Name+"/"+region+"/"+namespace, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeMetricMetaList(ctx, account, region, namespace)
+	})
+	metas, _ := value.([]*metricMeta)
+
+	var metrics []discoveredMetric
+	for _, meta := range metas {
+		name := tea.StringValue(meta.MetricName)
+		if name == "" || known[name] {
+			continue
+		}
+		metrics = append(metrics, discoveredMetric{
+			name:       name,
+			desc:       discoveredDesc(prefix+"_"+snakeCase(name), metaHelp(meta), labels),
+			statistics: splitList(tea.StringValue(meta.Statistics)),
+		})
+	}
+	return metrics, err
+}
+
+// collectDiscovered 在采集器开启发现模式时，对 namespace 下未内置且未被过滤的指标调用 collect，
+// 统计值在 defaults 之后依次回退到元数据中的统计值
+func collectDiscovered(ctx context.Context, group *errorGroup, conf *Config, collector string, account Account, region string,
+	namespace string, prefix string, labels []string, known map[string]bool, defaults []string,
+	collect func(metricName string, desc *prometheus.Desc, defaultStatistics []string)) {
+	collectorConf := conf.collector(collector)
+	if !collectorConf.Discovery {
+		return
+	}
+	metrics, err := discoverMetrics(ctx, conf, account, region, namespace, prefix, labels, known)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe metric meta list", "err", err, "namespace", namespace, "account", account.Name, "region", region)
+		group.fail(err)
+	}
+	for _, m := range metrics {
+		if collectorConf.metricEnabled(m.name) {
+			collect(m.name, m.desc, append(append([]string(nil), defaults...), m.statistics...))
+		}
+	}
+}
+
+func discoveredDesc(fqName, help string, labels []string) *prometheus.Desc {
+	key := fqName + "/" + strings.Join(labels, ",")
+	if d, ok := discoveredDescs.Load(key); ok {
+		return d.(*prometheus.Desc)
+	}
+	d, _ := discoveredDescs.LoadOrStore(key, newDesc(fqName, help, labels, nil))
+	return d.(*prometheus.Desc)
+}
+
+// metaHelp 与内置指标的说明格式相同，如 ActiveConnection，TCP活跃连接数，单位 Count
+func metaHelp(meta *metricMeta) string {
+	help := tea.StringValue(meta.MetricName)
+	if description := tea.StringValue(meta.Description); description != "" {
+		help += "，" + description
+	}
+	if unit := tea.StringValue(meta.Unit); unit != "" {
+		help += "，单位 " + unit
+	}
+	return help
+}
+
+// snakeCase 将 InstanceQps、StatusCode5xx、net_rx.rate 转换为 instance_qps、status_code_5xx、net_rx_rate，
+// 字母后的数字前加下划线，与内置指标的命名一致
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && unicode.IsDigit(r):
+			if i > 0 && unicode.IsLetter(runes[i-1]) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		case r < unicode.MaxASCII && unicode.IsLower(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func describeMetricMetaList(ctx context.Context, account Account, region string, namespace string) ([]*metricMeta, error) {
+	client, _err := cmsClient(account, region)
+	if _err != nil {
+		return nil, _err
+	}
+
+	var metas []*metricMeta
+	for pageNumber := int32(1); ; pageNumber++ {
+		describeMetricMetaListRequest := &cms20190101.DescribeMetricMetaListRequest{
+			Namespace:  tea.String(namespace),
+			RegionId:   tea.String(region),
+			PageNumber: tea.Int32(pageNumber),
+			PageSize:   tea.Int32(inventoryPageSize),
+		}
+		var dataResponse *cms20190101.DescribeMetricMetaListResponse
+		_err := callAPI(ctx, "DescribeMetricMetaList", namespace, cmsEndpoint(region), func() (string, error) {
+			var _err error
+			dataResponse, _err = client.DescribeMetricMetaList(describeMetricMetaListRequest)
+			if _err != nil {
+				return "", _err
+			}
+			if dataResponse == nil || dataResponse.Body == nil {
+				return "error", errEmptyResponse
+			}
+			return tea.StringValue(dataResponse.Body.Code), nil
+		})
+		if _err != nil {
+			return nil, _err
+		}
+		if tea.StringValue(dataResponse.Body.Code) != "200" {
+			return nil, fmt.Errorf("the result returned by the server is not 200, code: %s, message: %s",
+				tea.StringValue(dataResponse.Body.Code), tea.StringValue(dataResponse.Body.Message))
+		}
+
+		var page []*metricMeta
+		if dataResponse.Body.Resources != nil {
+			page = dataResponse.Body.Resources.Resource
+		}
+		metas = append(metas, page...)
+		total, _ := strconv.Atoi(tea.StringValue(dataResponse.Body.TotalCount))
+		if len(page) < inventoryPageSize || len(metas) >= total {
+			return metas, nil
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ActiveConnection":        "active_connection",
+		"InstanceQps":             "instance_qps",
+		"StatusCode5xx":           "status_code_5xx",
+		"InstanceUpstreamCode4xx": "instance_upstream_code_4xx",
+		"CPUUtilization":          "cpu_utilization",
+		"net_rx.rate":             "net_rx_rate",
+		"memory_usedutilization":  "memory_usedutilization",
+		"Ipv6Traffic":             "ipv_6_traffic",
+		"disk_readbytes":          "disk_readbytes",
+		"Top10Qps":                "top_10_qps",
+		"load_1m":                 "load_1m",
+		"_Value_":                 "value",
+	}
+	for name, want := range tests {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// 带数字的内置 slb 指标名与发现模式生成的名称一致，如 StatusCode5xx 为 status_code_5xx
+func TestSnakeCaseMatchesBuiltinNames(t *testing.T) {
+	value := reflect.ValueOf(NewSlbCollector()).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if !strings.ContainsAny(value.Type().Field(i).Name, "0123456789") {
+			continue
+		}
+		desc := value.Field(i).Interface().(*prometheus.Desc)
+		m, ok := descMetas.Load(desc)
+		if !ok {
+			t.Fatalf("%s: desc not created by newDesc", value.Type().Field(i).Name)
+		}
+		name := value.Type().Field(i).Name
+		if want := "aliyun_slb_" + snakeCase(name); m.(descMeta).fqName != want {
+			t.Errorf("%s: builtin name %s, discovered name %s", name, m.(descMeta).fqName, want)
+		}
+	}
+}
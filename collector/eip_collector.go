@@ -1,14 +1,18 @@
 package collector
 
 import (
-	"encoding/json"
+	"context"
+	"github.com/alibabacloud-go/tea/tea"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 )
 
+var eipLabels = []string{"account", "user_id", "instance_id", "ip", "region"}
+
 type eipCollector struct {
 	NetRxRate             *prometheus.Desc
 	NetRxPkgsRate         *prometheus.Desc
@@ -22,46 +26,46 @@ type eipCollector struct {
 
 func NewEipCollector() *eipCollector {
 	return &eipCollector{
-		NetRxRate: prometheus.NewDesc(
+		NetRxRate: newDesc(
 			"aliyun_eip_net_rx_rate",
 			"net_rx.rate,流入带宽，单位 bit/s",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		NetRxPkgsRate: prometheus.NewDesc(
+		NetRxPkgsRate: newDesc(
 			"aliyun_eip_net_rx_pkgs_rate",
 			"net_rxPkgs.rate,流入包速率，单位 Packets/s",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		NetTxRate: prometheus.NewDesc(
+		NetTxRate: newDesc(
 			"aliyun_eip_net_tx_rate",
 			"net_tx.rate,流出带宽，单位 bit/s",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		NetTxPkgsRate: prometheus.NewDesc(
+		NetTxPkgsRate: newDesc(
 			"aliyun_eip_net_tx_pkgs_rate",
 			"net_txPkgs.rate,流出包速率，单位 Packets/s",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		OutRatelimitDropSpeed: prometheus.NewDesc(
+		OutRatelimitDropSpeed: newDesc(
 			"aliyun_eip_out_rate_limit_drop_speed",
 			"out_ratelimit_drop_speed,限速丢包速率，单位 Packets/s",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		NetInRatePercentage: prometheus.NewDesc(
+		NetInRatePercentage: newDesc(
 			"aliyun_eip_net_in_rate_percentage",
 			"net_in.rate_percentage,网络流入带宽利用率，单位 %",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
-		NetOutRatePercentage: prometheus.NewDesc(
+		NetOutRatePercentage: newDesc(
 			"aliyun_eip_net_out_rate_percentage",
 			"net_out.rate_percentage,网络流出带宽利用率，单位 %",
-			[]string{"user_id", "instance_id", "ip"},
+			eipLabels,
 			nil,
 		),
 	}
@@ -78,63 +82,65 @@ func (e *eipCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (e *eipCollector) Collect(ch chan<- prometheus.Metric) {
+	e.collectContext(context.Background(), ch)
+}
+
+func (e *eipCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
 	e.sMutex.Lock()
 	defer e.sMutex.Unlock()
 
-	eipInstanceMap := make(map[string]string)
-	eips := describeEipAddressesResponse().Body.EipAddresses.EipAddress
-	for _,v := range eips {
-		eipInstanceMap[*v.AllocationId] = *v.IpAddress
+	conf := currentConfig()
+	if !conf.collector("eip").enabled() {
+		return
 	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return e.collectRegion(ctx, ch, conf, account, region)
+	})
+	observeCollector("eip", start, success)
+}
 
-	value := reflect.ValueOf(e)
-	types := reflect.TypeOf(e)
-	for i := 0; i < types.Elem().NumField()-1; i++ {
-		metricName := strings.Split(value.Elem().FieldByIndex([]int{i, 1}).String(), ",")[0]
-		eName := types.Elem().Field(i).Name
-
-		var d interface{}
-
-		response, err := describeMetricLastResponse(metricName, "acs_vpc_eip")
-
+func (e *eipCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	collectorConf := conf.collector("eip")
+	eipInstanceMap := make(map[string]string)
+	if collectorConf.enrichLabels() {
+		eips, err := eipAddresses(ctx, conf, account, region)
 		if err != nil {
-			level.Error(logger).Log("msg", err)
-			break
+			level.Error(logger).Log("msg", "Failed to describe eip addresses", "err", err, "account", account.Name, "region", region)
 		}
-
-		if *response.Body.Code != "200" {
-			level.Error(logger).Log("msg", "The result returned by the server is not 200", "code", *response.Body.Code, "metric", metricName)
-			break
+		for _, v := range eips {
+			eipInstanceMap[tea.StringValue(v.AllocationId)] = tea.StringValue(v.IpAddress)
 		}
+	}
 
-		err = json.Unmarshal([]byte(*response.Body.Datapoints), &d)
-		if err != nil {
-			level.Error(logger).Log("msg", err)
+	labelValues := func(point datapoint) []string {
+		return []string{
+			account.Name,
+			point.dimension("userId"),
+			point.dimension("instanceId"),
+			eipInstanceMap[point.dimension("instanceId")],
+			region,
 		}
+	}
+	var group errorGroup
+	collect := func(metricName string, desc *prometheus.Desc, defaultStatistics []string) {
+		group.run(func() error {
+			return fetchMetric(ctx, ch, conf, "eip", account, region, "acs_vpc_eip", metricName, desc, defaultStatistics, labelValues)
+		})
+	}
 
-		datapoints := d.([]interface{})
-		for _, datapoint := range datapoints {
-			metricData := datapoint.(map[string]interface{})
-			_, ok := metricData["Average"]
-			if ok {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(eName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Average"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					eipInstanceMap[metricData["instanceId"].(string)],
-				)
-			} else {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(eName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Value"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					eipInstanceMap[metricData["instanceId"].(string)],
-				)
-			}
+	known := make(map[string]bool)
+	value := reflect.ValueOf(e)
+	types := reflect.TypeOf(e)
+	for i := 0; i < types.Elem().NumField()-1; i++ {
+		metricName := strings.Split(value.Elem().FieldByIndex([]int{i, 1}).String(), ",")[0]
+		known[metricName] = true
+		if !collectorConf.metricEnabled(metricName) {
+			continue
 		}
+		collect(metricName, value.Elem().Field(i).Interface().(*prometheus.Desc), []string{"Average", "Value"})
 	}
+	// 发现模式下同时采集云监控中未内置的指标
+	collectDiscovered(ctx, &group, conf, "eip", account, region, "acs_vpc_eip", "aliyun_eip", eipLabels, known, []string{"Average", "Value"}, collect)
+	return group.wait()
 }
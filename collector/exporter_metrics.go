@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cmsPagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aliyun_exporter_cms_pages_total",
+			Help: "DescribeMetricLast 获取的分页数",
+		},
+		[]string{"namespace", "metric"},
+	)
+	metricErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aliyun_exporter_metric_errors_total",
+			Help: "获取云监控指标失败的次数",
+		},
+		[]string{"namespace", "metric"},
+	)
+	datapointsSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aliyun_exporter_datapoints_skipped_total",
+			Help: "无法解析或缺少统计值而跳过的云监控数据点个数",
+		},
+		[]string{"namespace", "metric"},
+	)
+	apiRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aliyun_exporter_api_requests_total",
+			Help: "阿里云接口调用次数，code 为接口返回码",
+		},
+		[]string{"api", "namespace", "code"},
+	)
+	apiRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aliyun_exporter_api_retries_total",
+			Help: "阿里云接口因限流或网络错误重试的次数",
+		},
+		[]string{"api", "namespace"},
+	)
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aliyun_exporter_api_request_duration_seconds",
+			Help:    "阿里云接口调用耗时，单位 s",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"api", "namespace"},
+	)
+	collectorUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aliyun_exporter_collector_up",
+			Help: "collector 最近一次采集是否成功",
+		},
+		[]string{"collector"},
+	)
+	collectorDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aliyun_exporter_collector_duration_seconds",
+			Help: "collector 最近一次采集的耗时，单位 s",
+		},
+		[]string{"collector"},
+	)
+	lastSuccessTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aliyun_exporter_last_success_timestamp_seconds",
+			Help: "collector 最近一次采集成功的时间戳",
+		},
+		[]string{"collector"},
+	)
+)
+
+// ExporterMetrics 返回 exporter 自身的监控指标
+func ExporterMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		cmsPagesTotal,
+		metricErrorsTotal,
+		datapointsSkippedTotal,
+		apiRequestsTotal,
+		apiRetriesTotal,
+		apiRequestDuration,
+		rateLimiterCollector{},
+		collectorUp,
+		collectorDuration,
+		lastSuccessTimestamp,
+	}
+}
+
+// observeRequest 记录一次接口调用
+func observeRequest(api, namespace string, start time.Time, code string) {
+	apiRequestDuration.WithLabelValues(api, namespace).Observe(time.Since(start).Seconds())
+	apiRequestsTotal.WithLabelValues(api, namespace, code).Inc()
+}
+
+func errorCode(err error) string {
+	if err == nil {
+		return "200"
+	}
+	var sdkErr *tea.SDKError
+	if errors.As(err, &sdkErr) && tea.StringValue(sdkErr.Code) != "" {
+		return tea.StringValue(sdkErr.Code)
+	}
+	return "error"
+}
+
+// observeCollector 记录 collector 一次采集的结果
+func observeCollector(collector string, start time.Time, success bool) {
+	collectorDuration.WithLabelValues(collector).Set(time.Since(start).Seconds())
+	if success {
+		collectorUp.WithLabelValues(collector).Set(1)
+		lastSuccessTimestamp.WithLabelValues(collector).SetToCurrentTime()
+	} else {
+		collectorUp.WithLabelValues(collector).Set(0)
+	}
+}
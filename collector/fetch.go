@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errorGroup 并发执行一组函数，wait 等待全部结束后返回最后一个错误
+type errorGroup struct {
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+	err   error
+}
+
+func (g *errorGroup) run(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *errorGroup) fail(err error) {
+	g.mutex.Lock()
+	g.err = err
+	g.mutex.Unlock()
+}
+
+func (g *errorGroup) wait() error {
+	g.wg.Wait()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.err
+}
+
+// fetchMetric 在 apiPool 中获取一个云监控指标的全部数据点并输出，labelValues 返回数据点对应的标签值。
+// 单个指标失败不影响其它指标，已获取到的分页数据照常输出；ctx 结束后不再发起新的请求
+func fetchMetric(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, collector string, account Account, region string,
+	namespace string, metricName string, desc *prometheus.Desc, defaultStatistics []string, labelValues func(point datapoint) []string) error {
+	var datapoints []datapoint
+	var err error
+	runErr := apiPool.run(ctx, conf.concurrency(), func() {
+		datapoints, err = describeMetricLastDatapoints(ctx, account, metricName, namespace, region, conf.period(collector), conf.pageLength())
+	})
+	if runErr != nil {
+		err = runErr
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", err, "metric", metricName, "namespace", namespace, "account", account.Name, "region", region)
+		metricErrorsTotal.WithLabelValues(namespace, metricName).Inc()
+	}
+
+	collectorConf := conf.collector(collector)
+	for _, point := range datapoints {
+		if !sendDatapoint(ch, desc, point, collectorConf, metricName, defaultStatistics, labelValues(point)...) {
+			datapointsSkippedTotal.WithLabelValues(namespace, metricName).Inc()
+		}
+	}
+	return err
+}
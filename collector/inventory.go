@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// inventoryCache 缓存各账号、地域的实例列表，过期前不重复调用实例列表接口
+type inventoryCache struct {
+	mutex   sync.Mutex
+	entries map[string]*inventoryEntry
+	// 上一次清理长时间未读取的缓存的时间
+	pruned time.Time
+}
+
+type inventoryEntry struct {
+	mutex   sync.Mutex
+	value   interface{}
+	updated time.Time
+	// 缓存失败时最近一次失败的错误和时间
+	err    error
+	failed time.Time
+	// 最后一次读取的时间，由 inventoryCache.mutex 保护
+	read time.Time
+	ttl  time.Duration
+}
+
+var inventory = &inventoryCache{entries: make(map[string]*inventoryEntry)}
+
+// get 返回 key 对应的实例列表，超过 ttl 时调用 fetch 刷新；
+// 刷新失败时继续返回上一次成功获取的列表和错误
+func (c *inventoryCache) get(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	return c.load(key, ttl, false, fetch)
+}
+
+// getOptional 与 get 相同，但失败也缓存 ttl，期间不再调用 fetch，直接返回上一次成功获取的值和失败的错误，
+// 用于获取失败时不需要每次重试的数据，如配额
+func (c *inventoryCache) getOptional(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	return c.load(key, ttl, true, fetch)
+}
+
+func (c *inventoryCache) load(key string, ttl time.Duration, cacheErrors bool, fetch func() (interface{}, error)) (interface{}, error) {
+	now := time.Now()
+	c.mutex.Lock()
+	c.prune(now, ttl)
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &inventoryEntry{}
+		c.entries[key] = entry
+	}
+	entry.read = now
+	entry.ttl = ttl
+	c.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if !entry.updated.IsZero() && time.Since(entry.updated) < ttl {
+		return entry.value, nil
+	}
+	if cacheErrors && entry.err != nil && time.Since(entry.failed) < ttl {
+		return entry.value, entry.err
+	}
+	value, err := fetch()
+	if err != nil {
+		entry.err = err
+		entry.failed = time.Now()
+		return entry.value, err
+	}
+	entry.value = value
+	entry.updated = time.Now()
+	entry.err = nil
+	level.Debug(logger).Log("msg", "Refreshed inventory", "key", key)
+	return value, nil
+}
+
+// prune 删除超过两倍 ttl 没有读取的缓存，如已释放的 slb 的监听和服务器组，每个 ttl 最多清理一次
+func (c *inventoryCache) prune(now time.Time, ttl time.Duration) {
+	if now.Sub(c.pruned) < ttl {
+		return
+	}
+	c.pruned = now
+	for key, entry := range c.entries {
+		if now.Sub(entry.read) > 2*entry.ttl {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func loadBalancers(ctx context.Context, conf *Config, account Account, region string) ([]*loadBalancer, error) {
+	value, err := inventory.get("slb/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeLoadBalancers(ctx, account, region)
+	})
+	slbs, _ := value.([]*loadBalancer)
+	return slbs, err
+}
+
+func eipAddresses(ctx context.Context, conf *Config, account Account, region string) ([]*eipAddress, error) {
+	value, err := inventory.get("eip/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeEipAddresses(ctx, account, region)
+	})
+	eips, _ := value.([]*eipAddress)
+	return eips, err
+}
+
+func slbListeners(ctx context.Context, conf *Config, account Account, region string) ([]*listener, error) {
+	value, err := inventory.get("slb_listener/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeLoadBalancerListeners(ctx, account, region)
+	})
+	listeners, _ := value.([]*listener)
+	return listeners, err
+}
+
+func slbBackendServers(ctx context.Context, conf *Config, account Account, region string, loadBalancerId string) ([]*backendServer, error) {
+	value, err := inventory.get("slb_backend/"+account.Name+"/"+region+"/"+loadBalancerId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeBackendServers(ctx, account, region, loadBalancerId)
+	})
+	servers, _ := value.([]*backendServer)
+	return servers, err
+}
+
+func slbVServerGroups(ctx context.Context, conf *Config, account Account, region string, loadBalancerId string) ([]*vServerGroup, error) {
+	value, err := inventory.get("slb_vserver_group/"+account.Name+"/"+region+"/"+loadBalancerId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeVServerGroups(ctx, account, region, loadBalancerId)
+	})
+	groups, _ := value.([]*vServerGroup)
+	return groups, err
+}
+
+func slbVServerGroupBackendServers(ctx context.Context, conf *Config, account Account, region string, vServerGroupId string) ([]*vServerGroupBackendServer, error) {
+	value, err := inventory.get("slb_vserver_group_backend/"+account.Name+"/"+region+"/"+vServerGroupId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeVServerGroupBackendServers(ctx, account, region, vServerGroupId)
+	})
+	servers, _ := value.([]*vServerGroupBackendServer)
+	return servers, err
+}
+
+func slbServerCertificates(ctx context.Context, conf *Config, account Account, region string) ([]*serverCertificate, error) {
+	value, err := inventory.get("slb_server_certificate/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeServerCertificates(ctx, account, region)
+	})
+	certificates, _ := value.([]*serverCertificate)
+	return certificates, err
+}
+
+func slbCACertificates(ctx context.Context, conf *Config, account Account, region string) ([]*caCertificate, error) {
+	value, err := inventory.get("slb_ca_certificate/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeCACertificates(ctx, account, region)
+	})
+	certificates, _ := value.([]*caCertificate)
+	return certificates, err
+}
+
+func slbMasterSlaveServerGroups(ctx context.Context, conf *Config, account Account, region string, loadBalancerId string) ([]*masterSlaveServerGroup, error) {
+	value, err := inventory.get("slb_master_slave_group/"+account.Name+"/"+region+"/"+loadBalancerId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeMasterSlaveServerGroups(ctx, account, region, loadBalancerId)
+	})
+	groups, _ := value.([]*masterSlaveServerGroup)
+	return groups, err
+}
+
+func slbMasterSlaveBackendServers(ctx context.Context, conf *Config, account Account, region string, groupId string) ([]*masterSlaveBackendServer, error) {
+	value, err := inventory.get("slb_master_slave_group_backend/"+account.Name+"/"+region+"/"+groupId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeMasterSlaveBackendServers(ctx, account, region, groupId)
+	})
+	servers, _ := value.([]*masterSlaveBackendServer)
+	return servers, err
+}
+
+func slbAccessControlLists(ctx context.Context, conf *Config, account Account, region string) ([]*accessControlList, error) {
+	value, err := inventory.get("slb_acl/"+account.Name+"/"+region, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeAccessControlLists(ctx, account, region)
+	})
+	acls, _ := value.([]*accessControlList)
+	return acls, err
+}
+
+func slbAccessControlListAttribute(ctx context.Context, conf *Config, account Account, region string, aclId string) (*accessControlListAttribute, error) {
+	value, err := inventory.get("slb_acl_attribute/"+account.Name+"/"+region+"/"+aclId, conf.inventoryInterval(), func() (interface{}, error) {
+		return describeAccessControlListAttribute(ctx, account, region, aclId)
+	})
+	attribute, _ := value.(*accessControlListAttribute)
+	return attribute, err
+}
+
+func slbDomainExtensions(ctx context.Context, conf *Config, account Account, region string, loadBalancerId string, listenerPort int32) ([]*domainExtension, error) {
+	value, err := inventory.get(fmt.Sprintf("slb_domain_extension/%s/%s/%s/%d", account.Name, region, loadBalancerId, listenerPort), conf.inventoryInterval(), func() (interface{}, error) {
+		return describeDomainExtensions(ctx, account, region, loadBalancerId, listenerPort)
+	})
+	extensions, _ := value.([]*domainExtension)
+	return extensions, err
+}
+
+// slbQuotas 配额中心的配额按账号缓存，各地域共用；配额是可选的，获取失败时同样缓存 inventory_interval
+func slbQuotas(ctx context.Context, conf *Config, account Account) ([]*productQuota, error) {
+	value, err := inventory.getOptional("slb_quota/"+account.Name, conf.inventoryInterval(), func() (interface{}, error) {
+		quotas, err := describeProductQuotas(ctx, account, "slb")
+		if err != nil {
+			metricErrorsTotal.WithLabelValues("slb_inventory", "ListProductQuotas").Inc()
+		}
+		return quotas, err
+	})
+	quotas, _ := value.([]*productQuota)
+	return quotas, err
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInventoryCacheGet(t *testing.T) {
+	c := &inventoryCache{entries: make(map[string]*inventoryEntry)}
+	calls := 0
+	fetch := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+	if value, err := c.get("a", time.Hour, fetch); err != nil || value != 1 {
+		t.Fatalf("expected 1, got %v, %v", value, err)
+	}
+	if value, err := c.get("a", time.Hour, fetch); err != nil || value != 1 {
+		t.Fatalf("expected cached 1, got %v, %v", value, err)
+	}
+
+	// 刷新失败时返回上一次的值和错误
+	failed := errors.New("failed")
+	value, err := c.get("a", 0, func() (interface{}, error) { return nil, failed })
+	if !errors.Is(err, failed) || value != 1 {
+		t.Fatalf("expected stale 1 and error, got %v, %v", value, err)
+	}
+}
+
+func TestInventoryCachePrune(t *testing.T) {
+	c := &inventoryCache{entries: make(map[string]*inventoryEntry)}
+	fetch := func() (interface{}, error) { return 1, nil }
+	c.get("lb-1", time.Minute, fetch)
+	c.get("lb-2", time.Minute, fetch)
+
+	// lb-2 超过两倍 ttl 没有读取，下一次清理时被删除
+	c.mutex.Lock()
+	c.entries["lb-1"].read = time.Now().Add(-time.Minute)
+	c.entries["lb-2"].read = time.Now().Add(-3 * time.Minute)
+	c.pruned = time.Now().Add(-time.Minute)
+	c.mutex.Unlock()
+	c.get("lb-1", time.Minute, fetch)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries["lb-1"]; !ok {
+		t.Error("expected lb-1 to be kept")
+	}
+	if _, ok := c.entries["lb-2"]; ok {
+		t.Error("expected lb-2 to be pruned")
+	}
+}
+
+func TestInventoryCacheGetOptional(t *testing.T) {
+	c := &inventoryCache{entries: make(map[string]*inventoryEntry)}
+	calls := 0
+	failed := errors.New("failed")
+	fetch := func() (interface{}, error) {
+		calls++
+		return nil, failed
+	}
+
+	// 失败在 ttl 内缓存，不再调用 fetch
+	for i := 0; i < 3; i++ {
+		if _, err := c.getOptional("quota", time.Hour, fetch); !errors.Is(err, failed) {
+			t.Fatalf("expected cached error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+
+	// get 不缓存失败
+	for i := 0; i < 2; i++ {
+		c.get("list", time.Hour, fetch)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
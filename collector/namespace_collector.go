@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namespaceCollector 通用 collector，按配置文件中名为 name 的 namespaces 采集任意云监控命名空间，
+// 指标、统计值和标签都来自配置，重新加载配置后立即生效
+type namespaceCollector struct {
+	name   string
+	sMutex sync.Mutex
+}
+
+func NewNamespaceCollector(name string) *namespaceCollector {
+	return &namespaceCollector{name: name}
+}
+
+// Describe 指标由配置决定，不预先声明
+func (c *namespaceCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (c *namespaceCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectContext(context.Background(), ch)
+}
+
+func (c *namespaceCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.sMutex.Lock()
+	defer c.sMutex.Unlock()
+
+	conf := currentConfig()
+	ns, ok := conf.namespace(c.name)
+	if !ok || !ns.enabled() {
+		return
+	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return c.collectRegion(ctx, ch, conf, ns, account, region)
+	})
+	observeCollector(ns.Name, start, success)
+}
+
+func (c *namespaceCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, ns NamespaceConfig, account Account, region string) error {
+	dimensions, labels := ns.labels()
+
+	var (
+		group   errorGroup
+		metrics []discoveredMetric
+	)
+	if ns.Discovery {
+		var err error
+		metrics, err = discoverMetrics(ctx, conf, account, region, ns.Namespace, ns.prefix(), labels, nil)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to describe metric meta list", "err", err, "namespace", ns.Namespace, "account", account.Name, "region", region)
+			group.fail(err)
+		}
+	}
+	// 未开启发现或获取元数据失败时，使用配置中的指标
+	if len(metrics) == 0 {
+		for _, name := range ns.Metrics {
+			metrics = append(metrics, discoveredMetric{
+				name: name,
+				desc: discoveredDesc(ns.prefix()+"_"+snakeCase(name), name, labels),
+			})
+		}
+	}
+
+	labelValues := func(point datapoint) []string {
+		values := []string{account.Name}
+		for _, dimension := range dimensions {
+			values = append(values, point.dimension(dimension))
+		}
+		return append(values, region)
+	}
+	for _, m := range metrics {
+		if !ns.metricEnabled(m.name) {
+			continue
+		}
+		m := m
+		group.run(func() error {
+			return fetchMetric(ctx, ch, conf, ns.Name, account, region, ns.Namespace, m.name, m.desc, append([]string{"Average", "Value"}, m.statistics...), labelValues)
+		})
+	}
+	return group.wait()
+}
+
+var (
+	namespaceCollectors     = make(map[string]*cachedCollector)
+	namespaceCollectorMutex sync.Mutex
+)
+
+// configuredNamespaceCollectors 返回当前配置的每个通用 collector，
+// 缓存和超时按各自的命名空间输出；重新加载配置后新增的立即生效，删除的不再采集
+func configuredNamespaceCollectors() []*cachedCollector {
+	namespaceCollectorMutex.Lock()
+	defer namespaceCollectorMutex.Unlock()
+
+	var result []*cachedCollector
+	configured := make(map[string]bool)
+	for _, ns := range currentConfig().Namespaces {
+		key := ns.Name + "/" + ns.Namespace
+		configured[key] = true
+		c, ok := namespaceCollectors[key]
+		if !ok {
+			c = NewCachedCollector(ns.Namespace, NewNamespaceCollector(ns.Name))
+			namespaceCollectors[key] = c
+		}
+		result = append(result, c)
+	}
+	for key := range namespaceCollectors {
+		if !configured[key] {
+			delete(namespaceCollectors, key)
+		}
+	}
+	return result
+}
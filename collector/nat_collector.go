@@ -1,14 +1,15 @@
 package collector
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/go-kit/log/level"
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
 	"reflect"
 	"sync"
+	"time"
 )
 
+var natLabels = []string{"account", "user_id", "instance_id", "region"}
+
 type natCollector struct {
 	SessionActiveConnection           *prometheus.Desc
 	SessionActiveConnectionWaterLever *prometheus.Desc
@@ -33,112 +34,112 @@ type natCollector struct {
 
 func NewNatCollector() *natCollector {
 	return &natCollector{
-		SessionActiveConnection: prometheus.NewDesc(
+		SessionActiveConnection: newDesc(
 			"aliyun_nat_session_active_connection",
 			"SessionActiveConnection，并发连接数，单位 Count",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		SessionActiveConnectionWaterLever: prometheus.NewDesc(
+		SessionActiveConnectionWaterLever: newDesc(
 			"aliyun_nat_session_active_connection_waterlever",
 			"SessionActiveConnectionWaterLever，并发连接水位，单位 %",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		SessionLimitDropConnection: prometheus.NewDesc(
+		SessionLimitDropConnection: newDesc(
 			"aliyun_nat_session_limit_drop_connection",
 			"SessionLimitDropConnection，并发丢弃连接速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		SessionNewConnection: prometheus.NewDesc(
+		SessionNewConnection: newDesc(
 			"aliyun_nat_session_new_connection",
 			"SessionNewConnection，新建连接速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		SessionNewConnectionWaterLever: prometheus.NewDesc(
+		SessionNewConnectionWaterLever: newDesc(
 			"aliyun_nat_session_newconnection_waterlever",
 			"SessionNewConnectionWaterLever，新建连接水位，单位 %",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		SessionNewLimitDropConnection: prometheus.NewDesc(
+		SessionNewLimitDropConnection: newDesc(
 			"aliyun_nat_session_newlimit_drop_connection",
 			"SessionNewLimitDropConnection，新建丢弃连接速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		PPSRateInFromInside: prometheus.NewDesc(
+		PPSRateInFromInside: newDesc(
 			"aliyun_nat_ppsrate_in_from_inside",
 			"PPSRateInFromInside，从VPC来包速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		PPSRateInFromOutside: prometheus.NewDesc(
+		PPSRateInFromOutside: newDesc(
 			"aliyun_nat_ppsrate_in_from_outside",
 			"PPSRateInFromOutside，从公网来包速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		PPSRateOutToInside: prometheus.NewDesc(
+		PPSRateOutToInside: newDesc(
 			"aliyun_nat_ppsrate_out_to_inside",
 			"PPSRateOutToInside，入VPC包速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		PPSRateOutToOutside: prometheus.NewDesc(
+		PPSRateOutToOutside: newDesc(
 			"aliyun_nat_ppsrate_out_to_outside",
 			"PPSRateOutToOutside，入公网包速率，单位 Count/s",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BWRateInFromInside: prometheus.NewDesc(
+		BWRateInFromInside: newDesc(
 			"aliyun_nat_bwrate_in_from_inside",
 			"BWRateInFromInside，从VPC来流量速率，单位 bps",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BWRateInFromOutside: prometheus.NewDesc(
+		BWRateInFromOutside: newDesc(
 			"aliyun_nat_bwrate_in_from_outside",
 			"BWRateInFromOutside，从公网来流量速率，单位 bps",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BWRateOutToInside: prometheus.NewDesc(
+		BWRateOutToInside: newDesc(
 			"aliyun_nat_bwrate_out_to_inside",
 			"BWRateOutToInside，入VPC流量速率，单位 bps",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BWRateOutToOutside: prometheus.NewDesc(
+		BWRateOutToOutside: newDesc(
 			"aliyun_nat_bwrate_out_to_outside",
 			"BWRateOutToOutside，入公网流量速率，单位 bps",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BytesInFromInside: prometheus.NewDesc(
+		BytesInFromInside: newDesc(
 			"aliyun_nat_bytes_in_from_inside",
 			"BytesInFromInside，从VPC来流量，单位 Byte",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BytesInFromOutside: prometheus.NewDesc(
+		BytesInFromOutside: newDesc(
 			"aliyun_nat_bytes_in_from_outside",
 			"BytesInFromOutside，从公网来流量，单位 Byte",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BytesOutToInside: prometheus.NewDesc(
+		BytesOutToInside: newDesc(
 			"aliyun_nat_bytes_out_to_inside",
 			"BytesOutToInside，入VPC流量，单位 Byte",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
-		BytesOutToOutside: prometheus.NewDesc(
+		BytesOutToOutside: newDesc(
 			"aliyun_nat_bytes_out_to_outside",
 			"BytesOutToOutside，入公网流量，单位 Byte",
-			[]string{"user_id", "instance_id"},
+			natLabels,
 			nil,
 		),
 	}
@@ -166,43 +167,53 @@ func (n *natCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (n *natCollector) Collect(ch chan<- prometheus.Metric) {
+	n.collectContext(context.Background(), ch)
+}
+
+func (n *natCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
 	n.sMutex.Lock()
 	defer n.sMutex.Unlock()
 
+	conf := currentConfig()
+	if !conf.collector("nat").enabled() {
+		return
+	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return n.collectRegion(ctx, ch, conf, account, region)
+	})
+	observeCollector("nat", start, success)
+}
+
+func (n *natCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	collectorConf := conf.collector("nat")
+	labelValues := func(point datapoint) []string {
+		return []string{
+			account.Name,
+			point.dimension("userId"),
+			point.dimension("instanceId"),
+			region,
+		}
+	}
+	var group errorGroup
+	collect := func(metricName string, desc *prometheus.Desc, defaultStatistics []string) {
+		group.run(func() error {
+			return fetchMetric(ctx, ch, conf, "nat", account, region, "acs_nat_gateway", metricName, desc, defaultStatistics, labelValues)
+		})
+	}
+
+	known := make(map[string]bool)
 	value := reflect.ValueOf(n)
 	types := reflect.TypeOf(n)
 	for i := 0; i < types.Elem().NumField()-1; i++ {
 		metricName := types.Elem().Field(i).Name
-		var d interface{}
-
-		response, err := describeMetricLastResponse(metricName, "acs_nat_gateway")
-
-		if err != nil {
-			level.Error(logger).Log("msg", err)
-			break
-		}
-
-		if *response.Body.Code != "200" {
-			level.Error(logger).Log("msg", "The result returned by the server is not 200", "code", *response.Body.Code, "metric", metricName)
-			fmt.Println(*response)
-			break
-		}
-
-		err = json.Unmarshal([]byte(*response.Body.Datapoints), &d)
-		if err != nil {
-			level.Error(logger).Log("msg", err)
-		}
-
-		datapoints := d.([]interface{})
-		for _, datapoint := range datapoints {
-			metricData := datapoint.(map[string]interface{})
-			ch <- prometheus.MustNewConstMetric(
-				value.Elem().FieldByName(metricName).Interface().(*prometheus.Desc),
-				prometheus.GaugeValue,
-				metricData["Value"].(float64),
-				metricData["userId"].(string),
-				metricData["instanceId"].(string),
-			)
+		known[metricName] = true
+		if !collectorConf.metricEnabled(metricName) {
+			continue
 		}
+		collect(metricName, value.Elem().Field(i).Interface().(*prometheus.Desc), []string{"Value"})
 	}
+	// 发现模式下同时采集云监控中未内置的指标
+	collectDiscovered(ctx, &group, conf, "nat", account, region, "acs_nat_gateway", "aliyun_nat", natLabels, known, []string{"Value"}, collect)
+	return group.wait()
 }
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"context"
+	"sync"
+)
+
+// workerPool 限制所有 collector 同时进行的云监控请求数，limit 每次从配置中读取，重新加载后立即生效
+type workerPool struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	running int
+}
+
+var apiPool = newWorkerPool()
+
+func newWorkerPool() *workerPool {
+	p := &workerPool{}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// run 在 pool 中执行 f，同时执行的 f 不超过 limit 个；ctx 结束时不再执行 f，返回 ctx 的错误
+func (p *workerPool) run(ctx context.Context, limit int, f func()) error {
+	acquired := make(chan struct{})
+	defer close(acquired)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// 持有锁再唤醒，避免检查 ctx 之后、Wait 之前的 goroutine 错过通知
+			p.mutex.Lock()
+			p.cond.Broadcast()
+			p.mutex.Unlock()
+		case <-acquired:
+		}
+	}()
+
+	p.mutex.Lock()
+	for p.running >= limit && ctx.Err() == nil {
+		p.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		p.mutex.Unlock()
+		return err
+	}
+	p.running++
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		p.running--
+		p.mutex.Unlock()
+		p.cond.Broadcast()
+	}()
+	f()
+	return nil
+}
+
+// forEachRegion 并发采集每个账号的每个地域，全部成功时返回 true
+func forEachRegion(ctx context.Context, conf *Config, collect func(ctx context.Context, account Account, region string) error) bool {
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		success = true
+	)
+	for _, account := range conf.accounts() {
+		for _, region := range account.Regions {
+			wg.Add(1)
+			go func(account Account, region string) {
+				defer wg.Done()
+				if err := collect(ctx, account, region); err != nil {
+					mutex.Lock()
+					success = false
+					mutex.Unlock()
+				}
+			}(account, region)
+		}
+	}
+	wg.Wait()
+	return success
+}
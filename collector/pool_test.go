@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolLimit(t *testing.T) {
+	p := newWorkerPool()
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		running int
+		max     int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.run(context.Background(), 3, func() {
+				mutex.Lock()
+				running++
+				if running > max {
+					max = running
+				}
+				mutex.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if max != 3 {
+		t.Errorf("expected at most 3 running, got %d", max)
+	}
+}
+
+func TestWorkerPoolCanceled(t *testing.T) {
+	p := newWorkerPool()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go p.run(context.Background(), 1, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	defer close(release)
+
+	// pool 已满时，ctx 结束后立即返回，不等待正在执行的 f
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		start := time.Now()
+		err := p.run(ctx, 1, func() { t.Error("f must not run after ctx is done") })
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("run returned %s after ctx was done", elapsed)
+		}
+	}
+}
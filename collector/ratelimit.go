@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tokenBucket 令牌桶，令牌不足时预占后续的令牌并等待
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	waited time.Duration
+}
+
+// wait 获取一个令牌，rate 不大于 0 时不限制；ctx 结束时退还令牌，返回 ctx 的错误
+func (b *tokenBucket) wait(ctx context.Context, rate float64, burst int) error {
+	if rate <= 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	b.rate = rate
+	b.burst = math.Max(float64(burst), 1)
+	b.refill(time.Now())
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / rate * float64(time.Second))
+	}
+	b.mutex.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	// 等待结束后只累加实际等待的时间，计数器不会减少
+	start := time.Now()
+	err := sleepContext(ctx, delay)
+	slept := time.Since(start)
+	if slept > delay {
+		slept = delay
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.waited += slept
+	if err != nil {
+		// 请求没有发出，退还令牌
+		b.refill(time.Now())
+		b.tokens = math.Min(b.burst, b.tokens+1)
+		return err
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+type limiterKey struct {
+	api      string
+	endpoint string
+}
+
+var (
+	limiters     = make(map[limiterKey]*tokenBucket)
+	limiterMutex sync.Mutex
+)
+
+func limiterFor(api, endpoint string) *tokenBucket {
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
+
+	key := limiterKey{api: api, endpoint: endpoint}
+	b, ok := limiters[key]
+	if !ok {
+		b = &tokenBucket{}
+		limiters[key] = b
+	}
+	return b
+}
+
+var (
+	rateLimiterTokens = prometheus.NewDesc(
+		"aliyun_exporter_rate_limiter_tokens",
+		"限流器当前可用的令牌数，为负数时表示排队等待的请求",
+		[]string{"api", "endpoint"},
+		nil,
+	)
+	rateLimiterWaitSeconds = prometheus.NewDesc(
+		"aliyun_exporter_rate_limiter_wait_seconds_total",
+		"因限流等待的总时间，单位 s",
+		[]string{"api", "endpoint"},
+		nil,
+	)
+)
+
+// rateLimiterCollector 输出各接口、endpoint 限流器的状态
+type rateLimiterCollector struct{}
+
+func (rateLimiterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rateLimiterTokens
+	ch <- rateLimiterWaitSeconds
+}
+
+func (rateLimiterCollector) Collect(ch chan<- prometheus.Metric) {
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
+
+	now := time.Now()
+	for key, b := range limiters {
+		b.mutex.Lock()
+		if b.rate > 0 {
+			b.refill(now)
+		}
+		tokens, waited := b.tokens, b.waited
+		b.mutex.Unlock()
+
+		ch <- prometheus.MustNewConstMetric(rateLimiterTokens, prometheus.GaugeValue, tokens, key.api, key.endpoint)
+		ch <- prometheus.MustNewConstMetric(rateLimiterWaitSeconds, prometheus.CounterValue, waited.Seconds(), key.api, key.endpoint)
+	}
+}
+
+// retryable 判断是否为限流或临时的网络错误
+func retryable(code string, err error) bool {
+	if strings.Contains(code, "Throttling") || code == "429" || code == "ServiceUnavailable" || code == "InternalError" {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+
+	var sdkErr *tea.SDKError
+	if errors.As(err, &sdkErr) {
+		status := tea.IntValue(sdkErr.StatusCode)
+		return status == 429 || status >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// backoff 指数退避，在 [0, min(maxDelay, baseDelay*2^attempt)) 中随机取值
+func backoff(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := float64(baseDelay) * math.Pow(2, float64(attempt))
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	return time.Duration(rand.Float64() * delay)
+}
+
+// callAPI 经过限流调用 call，对限流和临时的网络错误按配置重试。
+// call 返回接口的返回码和错误，返回码为空时从错误中获取。
+// SDK 不支持 context，ctx 结束时不再等待正在进行的请求，请求本身由 api.timeout 限制
+func callAPI(ctx context.Context, api, namespace, endpoint string, call func() (string, error)) error {
+	type result struct {
+		code string
+		err  error
+	}
+
+	apiConf := currentConfig().API
+	for attempt := 0; ; attempt++ {
+		if err := limiterFor(api, endpoint).wait(ctx, apiConf.rateLimit(api), apiConf.burst()); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		done := make(chan result, 1)
+		go func() {
+			code, err := call()
+			done <- result{code: code, err: err}
+		}()
+		var r result
+		select {
+		case r = <-done:
+		case <-ctx.Done():
+			observeRequest(api, namespace, start, "canceled")
+			return ctx.Err()
+		}
+		if r.code == "" {
+			r.code = errorCode(r.err)
+		}
+		observeRequest(api, namespace, start, r.code)
+
+		if attempt >= apiConf.maxRetries() || !retryable(r.code, r.err) {
+			return r.err
+		}
+		apiRetriesTotal.WithLabelValues(api, namespace).Inc()
+		delay := backoff(attempt, apiConf.retryBaseDelay(), apiConf.retryMaxDelay())
+		level.Debug(logger).Log("msg", "Retrying aliyun api", "api", api, "endpoint", endpoint, "code", r.code, "attempt", attempt+1, "delay", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
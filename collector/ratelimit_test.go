@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := &tokenBucket{}
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := b.wait(context.Background(), 0, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected no wait without rate limit, waited %s", elapsed)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	b := &tokenBucket{}
+	start := time.Now()
+	// 容量为 2，前两个令牌不需要等待，第三个等待 1/rate
+	for i := 0; i < 3; i++ {
+		if err := b.wait(context.Background(), 10, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	if elapsed < 80*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected to wait about 100ms, waited %s", elapsed)
+	}
+	if b.waited < 80*time.Millisecond || b.waited > 120*time.Millisecond {
+		t.Errorf("expected waited about 100ms, got %s", b.waited)
+	}
+}
+
+func TestTokenBucketCanceled(t *testing.T) {
+	b := &tokenBucket{}
+	if err := b.wait(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx, 1, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	// 没有发出的请求退还令牌，等待时间只计实际等待的部分
+	b.mutex.Lock()
+	tokens, waited := b.tokens, b.waited
+	b.mutex.Unlock()
+	if tokens < -0.1 || tokens > 0.5 {
+		t.Errorf("expected the token to be refunded, got %f tokens", tokens)
+	}
+	if waited < 15*time.Millisecond || waited > 100*time.Millisecond {
+		t.Errorf("expected waited about 20ms, got %s", waited)
+	}
+
+	// 再次取消时等待时间只会增加
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx, 1, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	b.mutex.Lock()
+	total := b.waited
+	b.mutex.Unlock()
+	if total < waited+15*time.Millisecond {
+		t.Errorf("expected waited to grow from %s, got %s", waited, total)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt := 0; attempt < 10; attempt++ {
+		limit := base << attempt
+		if limit > max {
+			limit = max
+		}
+		for i := 0; i < 100; i++ {
+			if delay := backoff(attempt, base, max); delay < 0 || delay >= limit {
+				t.Fatalf("attempt %d: delay %s out of [0, %s)", attempt, delay, limit)
+			}
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		err  error
+		want bool
+	}{
+		{name: "throttling", code: "Throttling.User", want: true},
+		{name: "too many requests", code: "429", want: true},
+		{name: "service unavailable", code: "ServiceUnavailable", want: true},
+		{name: "internal error", code: "InternalError", want: true},
+		{name: "invalid parameter", code: "InvalidParameter", err: errors.New("invalid"), want: false},
+		{name: "success", code: "200", want: false},
+		{name: "sdk 503", err: tea.NewSDKError(map[string]interface{}{"code": "Unknown", "statusCode": 503}), want: true},
+		{name: "sdk 429", err: tea.NewSDKError(map[string]interface{}{"code": "Unknown", "statusCode": 429}), want: true},
+		{name: "sdk 400", err: tea.NewSDKError(map[string]interface{}{"code": "InvalidParameter", "statusCode": 400}), want: false},
+		{name: "net error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "eof", err: fmt.Errorf("read: %w", io.EOF), want: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "other error", err: errors.New("other"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.code, tt.err); got != tt.want {
+				t.Errorf("retryable(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
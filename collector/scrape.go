@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var scrapeTimedOut = prometheus.NewDesc(
+	"aliyun_exporter_scrape_timed_out",
+	"本次抓取是否因超时只返回了部分指标，1 表示超时",
+	[]string{"namespace"},
+	nil,
+)
+
+// ScrapeContext 根据 Prometheus 的 X-Prometheus-Scrape-Timeout-Seconds 请求头生成本次抓取的 ctx，
+// 超时时间减去 polling.scrape_timeout_margin，留出输出指标的时间；没有请求头时不设超时
+func ScrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - currentConfig().scrapeTimeoutMargin()
+	if timeout <= 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// scrapeCollector 使用本次抓取的 ctx 采集，超时后返回已获取的指标
+type scrapeCollector struct {
+	ctx        context.Context
+	collectors []*cachedCollector
+}
+
+// WithContext 返回使用 ctx 采集 collectors 和 namespaces 中通用 collector 的 collector，
+// 每次抓取注册到新的 Registry 中
+func WithContext(ctx context.Context, collectors ...*cachedCollector) prometheus.Collector {
+	return &scrapeCollector{ctx: ctx, collectors: append(append([]*cachedCollector{}, collectors...), configuredNamespaceCollectors()...)}
+}
+
+func (s *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range s.collectors {
+		c.Describe(ch)
+	}
+	ch <- cacheAge
+	ch <- scrapeTimedOut
+}
+
+func (s *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for _, c := range s.collectors {
+		wg.Add(1)
+		go func(c *cachedCollector) {
+			defer wg.Done()
+			c.collectContext(s.ctx, ch)
+
+			timedOut := 0.0
+			if s.ctx.Err() == context.DeadlineExceeded {
+				timedOut = 1
+			}
+			ch <- prometheus.MustNewConstMetric(scrapeTimedOut, prometheus.GaugeValue, timedOut, c.namespace)
+		}(c)
+	}
+	wg.Wait()
+}
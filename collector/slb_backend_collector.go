@@ -0,0 +1,267 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 后端服务器健康检查状态对应的值，其它状态按 unavailable 处理
+var backendHealthValues = map[string]float64{
+	"normal":      1,
+	"abnormal":    0,
+	"unavailable": -1,
+}
+
+// 服务器组的类型
+const (
+	groupTypeVServer     = "vserver"
+	groupTypeMasterSlave = "master_slave"
+)
+
+// slbBackendCollector 输出 slb 每个后端服务器的健康检查状态和权重，以及虚拟服务器组、主备服务器组
+type slbBackendCollector struct {
+	BackendServerHealth *prometheus.Desc
+	BackendServerWeight *prometheus.Desc
+	GroupInfo           *prometheus.Desc
+	GroupBackendServers *prometheus.Desc
+	GroupListener       *prometheus.Desc
+	MasterSlaveServer   *prometheus.Desc
+	sMutex              sync.Mutex
+}
+
+func NewSlbBackendCollector() *slbBackendCollector {
+	return &slbBackendCollector{
+		BackendServerHealth: prometheus.NewDesc(
+			"aliyun_slb_backend_server_health",
+			"slb 后端服务器健康检查状态，1 为 normal，0 为 abnormal，-1 为 unavailable（未开启健康检查等）",
+			[]string{"account", "instance_id", "port", "protocol", "server_id", "server_ip", "backend_port", "vserver_group", "region"},
+			nil,
+		),
+		BackendServerWeight: prometheus.NewDesc(
+			"aliyun_slb_backend_server_weight",
+			"slb 后端服务器权重，vserver_group 为空时为默认服务器组",
+			[]string{"account", "instance_id", "server_id", "backend_port", "vserver_group", "region"},
+			nil,
+		),
+		GroupInfo: prometheus.NewDesc(
+			"aliyun_slb_vserver_group_info",
+			"slb 虚拟服务器组和主备服务器组，值固定为 1，type 为 vserver 或 master_slave",
+			[]string{"account", "instance_id", "vserver_group", "vserver_group_name", "type", "region"},
+			nil,
+		),
+		GroupBackendServers: prometheus.NewDesc(
+			"aliyun_slb_vserver_group_backend_servers",
+			"服务器组中的后端服务器个数",
+			[]string{"account", "instance_id", "vserver_group", "type", "region"},
+			nil,
+		),
+		GroupListener: prometheus.NewDesc(
+			"aliyun_slb_vserver_group_listener_info",
+			"服务器组关联的监听，值固定为 1",
+			[]string{"account", "instance_id", "vserver_group", "port", "protocol", "type", "region"},
+			nil,
+		),
+		MasterSlaveServer: prometheus.NewDesc(
+			"aliyun_slb_master_slave_server_info",
+			"主备服务器组中的后端服务器，值固定为 1，server_type 为 Master 或 Slave",
+			[]string{"account", "instance_id", "vserver_group", "server_id", "backend_port", "server_type", "region"},
+			nil,
+		),
+	}
+}
+
+func (s *slbBackendCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.BackendServerHealth
+	ch <- s.BackendServerWeight
+	ch <- s.GroupInfo
+	ch <- s.GroupBackendServers
+	ch <- s.GroupListener
+	ch <- s.MasterSlaveServer
+}
+
+func (s *slbBackendCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collectContext(context.Background(), ch)
+}
+
+func (s *slbBackendCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	s.sMutex.Lock()
+	defer s.sMutex.Unlock()
+
+	conf := currentConfig()
+	if !conf.collector("slb_backend").enabled() {
+		return
+	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return s.collectRegion(ctx, ch, conf, account, region)
+	})
+	observeCollector("slb_backend", start, success)
+}
+
+type listenerKey struct {
+	instanceId string
+	port       string
+	protocol   string
+}
+
+func (s *slbBackendCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	slbs, err := loadBalancers(ctx, conf, account, region)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe load balancers", "err", err, "account", account.Name, "region", region)
+	}
+	// 监听使用的虚拟服务器组或主备服务器组，DescribeHealthStatus 的返回中没有
+	vServerGroups := make(map[listenerKey]string)
+	listeners, listenerErr := slbListeners(ctx, conf, account, region)
+	if listenerErr != nil {
+		level.Error(logger).Log("msg", "Failed to describe load balancer listeners", "err", listenerErr, "account", account.Name, "region", region)
+		err = listenerErr
+	}
+	for _, l := range listeners {
+		key := listenerKey{tea.StringValue(l.LoadBalancerId), fmt.Sprint(tea.Int32Value(l.ListenerPort)), tea.StringValue(l.ListenerProtocol)}
+		groupId := tea.StringValue(l.VServerGroupId)
+		// 四层监听也可能使用主备服务器组
+		if groupId == "" && l.TCPListenerConfig != nil {
+			groupId = tea.StringValue(l.TCPListenerConfig.MasterSlaveServerGroupId)
+		}
+		if groupId == "" && l.UDPListenerConfig != nil {
+			groupId = tea.StringValue(l.UDPListenerConfig.MasterSlaveServerGroupId)
+		}
+		vServerGroups[key] = groupId
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+	)
+	for _, v := range slbs {
+		instanceId := tea.StringValue(v.LoadBalancerId)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lbErr error
+			runErr := apiPool.run(ctx, conf.concurrency(), func() {
+				lbErr = s.collectLoadBalancer(ctx, ch, conf, account, region, instanceId, vServerGroups)
+			})
+			if runErr != nil {
+				lbErr = runErr
+			}
+			if lbErr != nil {
+				errMutex.Lock()
+				err = lbErr
+				errMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// collectLoadBalancer 输出一个 slb 的后端服务器指标，健康检查状态每次都重新获取，服务器组和权重按 inventory_interval 缓存
+func (s *slbBackendCollector) collectLoadBalancer(ctx context.Context, ch chan<- prometheus.Metric, conf *Config,
+	account Account, region string, instanceId string, vServerGroups map[listenerKey]string) error {
+	var lastErr error
+	servers, err := describeHealthStatus(ctx, account, region, instanceId)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe health status", "err", err, "instance_id", instanceId, "account", account.Name, "region", region)
+		lastErr = err
+	}
+	for _, server := range servers {
+		port := fmt.Sprint(tea.Int32Value(server.ListenerPort))
+		protocol := tea.StringValue(server.Protocol)
+		value, ok := backendHealthValues[tea.StringValue(server.ServerHealthStatus)]
+		if !ok {
+			value = -1
+		}
+		ch <- prometheus.MustNewConstMetric(s.BackendServerHealth, prometheus.GaugeValue, value,
+			account.Name,
+			instanceId,
+			port,
+			protocol,
+			tea.StringValue(server.ServerId),
+			tea.StringValue(server.ServerIp),
+			fmt.Sprint(tea.Int32Value(server.Port)),
+			vServerGroups[listenerKey{instanceId, port, protocol}],
+			region,
+		)
+	}
+
+	defaultServers, err := slbBackendServers(ctx, conf, account, region, instanceId)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe load balancer attribute", "err", err, "instance_id", instanceId, "account", account.Name, "region", region)
+		lastErr = err
+	}
+	for _, server := range defaultServers {
+		ch <- prometheus.MustNewConstMetric(s.BackendServerWeight, prometheus.GaugeValue, float64(tea.Int32Value(server.Weight)),
+			account.Name, instanceId, tea.StringValue(server.ServerId), "", "", region)
+	}
+
+	groups, err := slbVServerGroups(ctx, conf, account, region, instanceId)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe vserver groups", "err", err, "instance_id", instanceId, "account", account.Name, "region", region)
+		lastErr = err
+	}
+	for _, group := range groups {
+		groupId := tea.StringValue(group.VServerGroupId)
+		ch <- prometheus.MustNewConstMetric(s.GroupInfo, prometheus.GaugeValue, 1,
+			account.Name, instanceId, groupId, tea.StringValue(group.VServerGroupName), groupTypeVServer, region)
+		if group.AssociatedObjects != nil && group.AssociatedObjects.Listeners != nil {
+			for _, l := range group.AssociatedObjects.Listeners.Listener {
+				ch <- prometheus.MustNewConstMetric(s.GroupListener, prometheus.GaugeValue, 1,
+					account.Name, instanceId, groupId, fmt.Sprint(tea.Int32Value(l.Port)), tea.StringValue(l.Protocol), groupTypeVServer, region)
+			}
+		}
+
+		groupServers, err := slbVServerGroupBackendServers(ctx, conf, account, region, groupId)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to describe vserver group attribute", "err", err, "vserver_group", groupId, "account", account.Name, "region", region)
+			lastErr = err
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.GroupBackendServers, prometheus.GaugeValue, float64(len(groupServers)),
+			account.Name, instanceId, groupId, groupTypeVServer, region)
+		for _, server := range groupServers {
+			ch <- prometheus.MustNewConstMetric(s.BackendServerWeight, prometheus.GaugeValue, float64(tea.Int32Value(server.Weight)),
+				account.Name, instanceId, tea.StringValue(server.ServerId), fmt.Sprint(tea.Int32Value(server.Port)), groupId, region)
+		}
+	}
+
+	masterSlaveGroups, err := slbMasterSlaveServerGroups(ctx, conf, account, region, instanceId)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe master slave server groups", "err", err, "instance_id", instanceId, "account", account.Name, "region", region)
+		lastErr = err
+	}
+	for _, group := range masterSlaveGroups {
+		groupId := tea.StringValue(group.MasterSlaveServerGroupId)
+		ch <- prometheus.MustNewConstMetric(s.GroupInfo, prometheus.GaugeValue, 1,
+			account.Name, instanceId, groupId, tea.StringValue(group.MasterSlaveServerGroupName), groupTypeMasterSlave, region)
+		if group.AssociatedObjects != nil && group.AssociatedObjects.Listeners != nil {
+			for _, l := range group.AssociatedObjects.Listeners.Listener {
+				ch <- prometheus.MustNewConstMetric(s.GroupListener, prometheus.GaugeValue, 1,
+					account.Name, instanceId, groupId, fmt.Sprint(tea.Int32Value(l.Port)), tea.StringValue(l.Protocol), groupTypeMasterSlave, region)
+			}
+		}
+
+		groupServers, err := slbMasterSlaveBackendServers(ctx, conf, account, region, groupId)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to describe master slave server group attribute", "err", err, "vserver_group", groupId, "account", account.Name, "region", region)
+			lastErr = err
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.GroupBackendServers, prometheus.GaugeValue, float64(len(groupServers)),
+			account.Name, instanceId, groupId, groupTypeMasterSlave, region)
+		for _, server := range groupServers {
+			port := fmt.Sprint(tea.Int32Value(server.Port))
+			ch <- prometheus.MustNewConstMetric(s.BackendServerWeight, prometheus.GaugeValue, float64(tea.Int32Value(server.Weight)),
+				account.Name, instanceId, tea.StringValue(server.ServerId), port, groupId, region)
+			ch <- prometheus.MustNewConstMetric(s.MasterSlaveServer, prometheus.GaugeValue, 1,
+				account.Name, instanceId, groupId, tea.StringValue(server.ServerId), port, tea.StringValue(server.ServerType), region)
+		}
+	}
+	return lastErr
+}
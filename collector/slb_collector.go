@@ -1,16 +1,19 @@
 package collector
 
 import (
-	"encoding/json"
+	"context"
+	"github.com/alibabacloud-go/tea/tea"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/promlog"
 	"reflect"
 	"sync"
+	"time"
 )
 
 var (
-	logger = promlog.New(&promlog.Config{})
+	logger    = promlog.New(&promlog.Config{})
+	slbLabels = []string{"account", "user_id", "instance_id", "port", "vip", "protocol", "instance_name", "region"}
 )
 
 type slbCollector struct {
@@ -45,195 +48,335 @@ type slbCollector struct {
 	InstanceTrafficRX                *prometheus.Desc
 	InstanceTrafficTX                *prometheus.Desc
 	InstanceTrafficTXUtilization     *prometheus.Desc
+	Qps                              *prometheus.Desc
+	Rt                               *prometheus.Desc
+	StatusCode2xx                    *prometheus.Desc
+	StatusCode3xx                    *prometheus.Desc
+	StatusCode4xx                    *prometheus.Desc
+	StatusCode5xx                    *prometheus.Desc
+	StatusCodeOther                  *prometheus.Desc
+	UpstreamCode4xx                  *prometheus.Desc
+	UpstreamCode5xx                  *prometheus.Desc
+	UpstreamRt                       *prometheus.Desc
+	InstanceQps                      *prometheus.Desc
+	InstanceRt                       *prometheus.Desc
+	InstanceStatusCode2xx            *prometheus.Desc
+	InstanceStatusCode3xx            *prometheus.Desc
+	InstanceStatusCode4xx            *prometheus.Desc
+	InstanceStatusCode5xx            *prometheus.Desc
+	InstanceStatusCodeOther          *prometheus.Desc
+	InstanceUpstreamCode4xx          *prometheus.Desc
+	InstanceUpstreamCode5xx          *prometheus.Desc
+	InstanceUpstreamRt               *prometheus.Desc
 	sMutex                           sync.Mutex
 }
 
 func NewSlbCollector() *slbCollector {
 	return &slbCollector{
-		ActiveConnection: prometheus.NewDesc(
+		ActiveConnection: newDesc(
 			"aliyun_slb_active_connection",
 			"ActiveConnection，TCP活跃连接数，单位 Count",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		MaxConnection: prometheus.NewDesc(
+		MaxConnection: newDesc(
 			"aliyun_slb_max_connection",
 			"MaxConnection，端口并发连接数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		NewConnection: prometheus.NewDesc(
+		NewConnection: newDesc(
 			"aliyun_slb_new_connection",
 			"NewConnection，TCP新建连接数，单位 Count",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		PacketRX: prometheus.NewDesc(
+		PacketRX: newDesc(
 			"aliyun_slb_packet_RX",
 			"PacketRX，每秒流出数据包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		PacketTX: prometheus.NewDesc(
+		PacketTX: newDesc(
 			"aliyun_slb_packet_TX",
 			"PacketTX，每秒流入数据包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		TrafficRXNew: prometheus.NewDesc(
+		TrafficRXNew: newDesc(
 			"aliyun_slb_traffic_rxnew",
 			"TrafficRXNew，流入带宽，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		TrafficTXNew: prometheus.NewDesc(
+		TrafficTXNew: newDesc(
 			"aliyun_slb_traffic_txnew",
 			"TrafficTXNew，流出带宽，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InactiveConnection: prometheus.NewDesc(
+		InactiveConnection: newDesc(
 			"aliyun_slb_inactive_connection",
 			"InactiveConnection，端口非活跃连接数，单位 Count",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		HeathyServerCount: prometheus.NewDesc(
+		HeathyServerCount: newDesc(
 			"aliyun_slb_heathy_servercount",
 			"HeathyServerCount，后端健康ECS实例个数，单位 Count",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		UnhealthyServerCount: prometheus.NewDesc(
+		UnhealthyServerCount: newDesc(
 			"aliyun_slb_unhealthy_servercount",
 			"UnhealthyServerCount，后端异常ECS实例个数，单位 Count",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		DropConnection: prometheus.NewDesc(
+		DropConnection: newDesc(
 			"aliyun_slb_drop_connection",
 			"DropConnection，监听每秒丢失连接数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		DropPacketRX: prometheus.NewDesc(
+		DropPacketRX: newDesc(
 			"aliyun_slb_drop_packet_RX",
 			"DropPacketRX，监听每秒丢失入包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		DropPacketTX: prometheus.NewDesc(
+		DropPacketTX: newDesc(
 			"aliyun_slb_drop_packet_TX",
 			"DropPacketTX，监听每秒丢失出包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		DropTrafficRX: prometheus.NewDesc(
+		DropTrafficRX: newDesc(
 			"aliyun_slb_drop_traffic_RX",
 			"DropTrafficRX，监听每秒丢失入bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		DropTrafficTX: prometheus.NewDesc(
+		DropTrafficTX: newDesc(
 			"aliyun_slb_drop_traffic_TX",
 			"DropTrafficTX，监听每秒丢失出bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceDropConnection: prometheus.NewDesc(
+		InstanceDropConnection: newDesc(
 			"aliyun_slb_instance_drop_connection",
 			"InstanceDropConnection，实例每秒丢失连接数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceDropPacketRX: prometheus.NewDesc(
+		InstanceDropPacketRX: newDesc(
 			"aliyun_slb_instance_drop_packet_RX",
 			"InstanceDropPacketRX，实例每秒丢失入包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceDropPacketTX: prometheus.NewDesc(
+		InstanceDropPacketTX: newDesc(
 			"aliyun_slb_instance_drop_packet_TX",
 			"InstanceDropPacketTX，实例每秒丢失出包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceDropTrafficRX: prometheus.NewDesc(
+		InstanceDropTrafficRX: newDesc(
 			"aliyun_slb_instance_drop_traffic_RX",
 			"InstanceDropTrafficRX，实例每秒丢失入bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceDropTrafficTX: prometheus.NewDesc(
+		InstanceDropTrafficTX: newDesc(
 			"aliyun_slb_instance_drop_traffic_TX",
 			"InstanceDropTrafficTX，实例每秒丢失出bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceActiveConnection: prometheus.NewDesc(
+		InstanceActiveConnection: newDesc(
 			"aliyun_slb_instance_active_connection",
 			"InstanceActiveConnection，实例活跃连接数，Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceInactiveConnection: prometheus.NewDesc(
+		InstanceInactiveConnection: newDesc(
 			"aliyun_slb_instance_inactive_connection",
 			"InstanceInactiveConnection，实例每秒非活跃连接数，Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceMaxConnection: prometheus.NewDesc(
+		InstanceMaxConnection: newDesc(
 			"aliyun_slb_instance_maxconnection",
 			"InstanceMaxConnection，实例每秒最大并发连接数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceMaxConnectionUtilization: prometheus.NewDesc(
+		InstanceMaxConnectionUtilization: newDesc(
 			"aliyun_slb_instance_maxconnection_utilization",
 			"InstanceMaxConnectionUtilization，最大连接数使用率，单位 %",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceNewConnection: prometheus.NewDesc(
+		InstanceNewConnection: newDesc(
 			"aliyun_slb_instance_new_connection",
 			"InstanceNewConnection，实例每秒新建连接数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceNewConnectionUtilization: prometheus.NewDesc(
+		InstanceNewConnectionUtilization: newDesc(
 			"aliyun_slb_instance_newconnection_utilization",
 			"InstanceNewConnectionUtilization，新建连接数使用率，单位 %",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstancePacketRX: prometheus.NewDesc(
+		InstancePacketRX: newDesc(
 			"aliyun_slb_instance_packet_RX",
 			"InstancePacketRX，实例每秒入包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstancePacketTX: prometheus.NewDesc(
+		InstancePacketTX: newDesc(
 			"aliyun_slb_instance_packet_TX",
 			"InstancePacketTX，实例每秒出包数，单位 Count/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceTrafficRX: prometheus.NewDesc(
+		InstanceTrafficRX: newDesc(
 			"aliyun_slb_instance_traffic_RX",
 			"InstanceTrafficRX，实例每秒入bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceTrafficTX: prometheus.NewDesc(
+		InstanceTrafficTX: newDesc(
 			"aliyun_slb_instance_traffic_TX",
 			"InstanceTrafficTX，实例每秒出bit数，单位 bit/s",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
 			nil,
 		),
-		InstanceTrafficTXUtilization: prometheus.NewDesc(
+		InstanceTrafficTXUtilization: newDesc(
 			"aliyun_slb_instance_traffic_TX_utilization",
 			"InstanceTrafficTXUtilization，网络流出带宽使用率，单位 %",
-			[]string{"user_id", "instance_id", "port", "vip", "instance_name"},
+			slbLabels,
+			nil,
+		),
+		Qps: newDesc(
+			"aliyun_slb_qps",
+			"Qps，七层监听每秒请求数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		Rt: newDesc(
+			"aliyun_slb_rt",
+			"Rt，七层监听请求平均延时，单位 ms",
+			slbLabels,
+			nil,
+		),
+		StatusCode2xx: newDesc(
+			"aliyun_slb_status_code_2xx",
+			"StatusCode2xx，七层监听返回给客户端的2xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		StatusCode3xx: newDesc(
+			"aliyun_slb_status_code_3xx",
+			"StatusCode3xx，七层监听返回给客户端的3xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		StatusCode4xx: newDesc(
+			"aliyun_slb_status_code_4xx",
+			"StatusCode4xx，七层监听返回给客户端的4xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		StatusCode5xx: newDesc(
+			"aliyun_slb_status_code_5xx",
+			"StatusCode5xx，七层监听返回给客户端的5xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		StatusCodeOther: newDesc(
+			"aliyun_slb_status_code_other",
+			"StatusCodeOther，七层监听返回给客户端的其它状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		UpstreamCode4xx: newDesc(
+			"aliyun_slb_upstream_code_4xx",
+			"UpstreamCode4xx，七层监听后端服务器返回的4xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		UpstreamCode5xx: newDesc(
+			"aliyun_slb_upstream_code_5xx",
+			"UpstreamCode5xx，七层监听后端服务器返回的5xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		UpstreamRt: newDesc(
+			"aliyun_slb_upstream_rt",
+			"UpstreamRt，七层监听后端服务器平均响应延时，单位 ms",
+			slbLabels,
+			nil,
+		),
+		InstanceQps: newDesc(
+			"aliyun_slb_instance_qps",
+			"InstanceQps，七层实例每秒请求数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceRt: newDesc(
+			"aliyun_slb_instance_rt",
+			"InstanceRt，七层实例请求平均延时，单位 ms",
+			slbLabels,
+			nil,
+		),
+		InstanceStatusCode2xx: newDesc(
+			"aliyun_slb_instance_status_code_2xx",
+			"InstanceStatusCode2xx，七层实例返回给客户端的2xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceStatusCode3xx: newDesc(
+			"aliyun_slb_instance_status_code_3xx",
+			"InstanceStatusCode3xx，七层实例返回给客户端的3xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceStatusCode4xx: newDesc(
+			"aliyun_slb_instance_status_code_4xx",
+			"InstanceStatusCode4xx，七层实例返回给客户端的4xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceStatusCode5xx: newDesc(
+			"aliyun_slb_instance_status_code_5xx",
+			"InstanceStatusCode5xx，七层实例返回给客户端的5xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceStatusCodeOther: newDesc(
+			"aliyun_slb_instance_status_code_other",
+			"InstanceStatusCodeOther，七层实例返回给客户端的其它状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceUpstreamCode4xx: newDesc(
+			"aliyun_slb_instance_upstream_code_4xx",
+			"InstanceUpstreamCode4xx，七层实例后端服务器返回的4xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceUpstreamCode5xx: newDesc(
+			"aliyun_slb_instance_upstream_code_5xx",
+			"InstanceUpstreamCode5xx，七层实例后端服务器返回的5xx状态码数，单位 Count/s",
+			slbLabels,
+			nil,
+		),
+		InstanceUpstreamRt: newDesc(
+			"aliyun_slb_instance_upstream_rt",
+			"InstanceUpstreamRt，七层实例后端服务器平均响应延时，单位 ms",
+			slbLabels,
 			nil,
 		),
 	}
@@ -271,93 +414,93 @@ func (s *slbCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- s.InstancePacketTX
 	ch <- s.InstanceTrafficRX
 	ch <- s.InstanceTrafficTX
+	ch <- s.Qps
+	ch <- s.Rt
+	ch <- s.StatusCode2xx
+	ch <- s.StatusCode3xx
+	ch <- s.StatusCode4xx
+	ch <- s.StatusCode5xx
+	ch <- s.StatusCodeOther
+	ch <- s.UpstreamCode4xx
+	ch <- s.UpstreamCode5xx
+	ch <- s.UpstreamRt
+	ch <- s.InstanceQps
+	ch <- s.InstanceRt
+	ch <- s.InstanceStatusCode2xx
+	ch <- s.InstanceStatusCode3xx
+	ch <- s.InstanceStatusCode4xx
+	ch <- s.InstanceStatusCode5xx
+	ch <- s.InstanceStatusCodeOther
+	ch <- s.InstanceUpstreamCode4xx
+	ch <- s.InstanceUpstreamCode5xx
+	ch <- s.InstanceUpstreamRt
 }
 
 func (s *slbCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collectContext(context.Background(), ch)
+}
+
+func (s *slbCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
 	s.sMutex.Lock()
 	defer s.sMutex.Unlock()
 
-	slbInstanceMap := make(map[string]string)
-	slbs := describeLoadBalancersResponse().Body.LoadBalancers.LoadBalancer
-	for _, v := range slbs {
-		slbInstanceMap[*v.LoadBalancerId] = *v.LoadBalancerName
+	conf := currentConfig()
+	if !conf.collector("slb").enabled() {
+		return
 	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return s.collectRegion(ctx, ch, conf, account, region)
+	})
+	observeCollector("slb", start, success)
+}
 
-	value := reflect.ValueOf(s)
-	types := reflect.TypeOf(s)
-	for i := 0; i < types.Elem().NumField()-1; i++ {
-		metricName := types.Elem().Field(i).Name
-		var d interface{}
-
-		response, err := describeMetricLastResponse(metricName, "acs_slb_dashboard")
-
+func (s *slbCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	collectorConf := conf.collector("slb")
+	slbInstanceMap := make(map[string]string)
+	if collectorConf.enrichLabels() {
+		slbs, err := loadBalancers(ctx, conf, account, region)
 		if err != nil {
-			level.Error(logger).Log("msg", err)
-			break
+			level.Error(logger).Log("msg", "Failed to describe load balancers", "err", err, "account", account.Name, "region", region)
 		}
-
-		if *response.Body.Code != "200" {
-			level.Error(logger).Log("msg", "The result returned by the server is not 200", "code", *response.Body.Code, "metric", metricName)
-			break
+		for _, v := range slbs {
+			slbInstanceMap[tea.StringValue(v.LoadBalancerId)] = tea.StringValue(v.LoadBalancerName)
 		}
+	}
 
-		err = json.Unmarshal([]byte(*response.Body.Datapoints), &d)
-		if err != nil {
-			level.Error(logger).Log("msg", err)
+	labelValues := func(point datapoint) []string {
+		return []string{
+			account.Name,
+			point.dimension("userId"),
+			point.dimension("instanceId"),
+			// 实例维度的指标没有 port、vip 和 protocol
+			point.dimension("port"),
+			point.dimension("vip"),
+			// 区分四层 tcp、udp 和七层 http、https 监听
+			point.dimension("protocol"),
+			slbInstanceMap[point.dimension("instanceId")],
+			region,
 		}
+	}
+	var group errorGroup
+	collect := func(metricName string, desc *prometheus.Desc, defaultStatistics []string) {
+		group.run(func() error {
+			return fetchMetric(ctx, ch, conf, "slb", account, region, "acs_slb_dashboard", metricName, desc, defaultStatistics, labelValues)
+		})
+	}
 
-		datapoints := d.([]interface{})
-		for _, datapoint := range datapoints {
-			metricData := datapoint.(map[string]interface{})
-			_, okPort := metricData["port"]
-			_, okVip := metricData["vip"]
-
-			if okPort && okVip {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(metricName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Average"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					metricData["port"].(string),
-					metricData["vip"].(string),
-					slbInstanceMap[metricData["instanceId"].(string)],
-				)
-			} else if okPort {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(metricName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Average"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					metricData["port"].(string),
-					"",
-					slbInstanceMap[metricData["instanceId"].(string)],
-				)
-			} else if okVip {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(metricName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Average"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					"",
-					metricData["vip"].(string),
-					slbInstanceMap[metricData["instanceId"].(string)],
-				)
-			} else {
-				ch <- prometheus.MustNewConstMetric(
-					value.Elem().FieldByName(metricName).Interface().(*prometheus.Desc),
-					prometheus.GaugeValue,
-					metricData["Average"].(float64),
-					metricData["userId"].(string),
-					metricData["instanceId"].(string),
-					"",
-					"",
-					slbInstanceMap[metricData["instanceId"].(string)],
-				)
-			}
+	known := make(map[string]bool)
+	value := reflect.ValueOf(s)
+	types := reflect.TypeOf(s)
+	for i := 0; i < types.Elem().NumField()-1; i++ {
+		metricName := types.Elem().Field(i).Name
+		known[metricName] = true
+		if !collectorConf.metricEnabled(metricName) {
+			continue
 		}
-
+		collect(metricName, value.Elem().Field(i).Interface().(*prometheus.Desc), []string{"Average"})
 	}
+	// 发现模式下同时采集云监控中未内置的指标
+	collectDiscovered(ctx, &group, conf, "slb", account, region, "acs_slb_dashboard", "aliyun_slb", slbLabels, known, []string{"Average"}, collect)
+	return group.wait()
 }
@@ -0,0 +1,443 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// slb 实例的状态，aliyun_slb_status 对每个状态输出一条，当前状态为 1
+var slbStatuses = []string{"active", "inactive", "locked"}
+
+// 监听的状态，aliyun_slb_listener_status 对每个状态输出一条，当前状态为 1
+var listenerStatuses = []string{"running", "stopped", "starting", "configuring", "stopping"}
+
+// slbInventoryCollector 输出 slb 实例、监听、证书、访问控制策略组的属性和状态以及访问控制策略组的条目数配额，实例列表与 slb collector 共用缓存
+type slbInventoryCollector struct {
+	Info           *prometheus.Desc
+	Status         *prometheus.Desc
+	CreateTime     *prometheus.Desc
+	ListenerInfo   *prometheus.Desc
+	ListenerStatus *prometheus.Desc
+	// 证书的过期时间和 https 监听使用的证书
+	CertificateExpiry   *prometheus.Desc
+	ListenerCertificate *prometheus.Desc
+	// 访问控制策略组的条目数和关联的监听
+	ACLEntries  *prometheus.Desc
+	ACLListener *prometheus.Desc
+	// 配额中心中访问控制策略组的条目数配额
+	ACLEntriesQuota *prometheus.Desc
+	sMutex          sync.Mutex
+}
+
+func NewSlbInventoryCollector() *slbInventoryCollector {
+	return &slbInventoryCollector{
+		Info: prometheus.NewDesc(
+			"aliyun_slb_info",
+			"slb 实例属性，值固定为 1",
+			[]string{"account", "instance_id", "instance_name", "address", "address_type", "network_type", "vpc_id", "vswitch_id",
+				"spec", "master_zone_id", "slave_zone_id", "pay_type", "region"},
+			nil,
+		),
+		Status: prometheus.NewDesc(
+			"aliyun_slb_status",
+			"slb 实例状态，当前状态为 1，其它状态为 0",
+			[]string{"account", "instance_id", "region", "status"},
+			nil,
+		),
+		CreateTime: prometheus.NewDesc(
+			"aliyun_slb_create_time_seconds",
+			"slb 实例的创建时间，单位 s",
+			[]string{"account", "instance_id", "region"},
+			nil,
+		),
+		ListenerInfo: prometheus.NewDesc(
+			"aliyun_slb_listener_info",
+			"slb 监听配置，值固定为 1，bandwidth 为 -1 表示不限带宽",
+			[]string{"account", "instance_id", "port", "protocol", "backend_port", "bandwidth", "scheduler", "vserver_group_id",
+				"health_check", "health_check_type", "health_check_interval", "healthy_threshold", "unhealthy_threshold", "region"},
+			nil,
+		),
+		ListenerStatus: prometheus.NewDesc(
+			"aliyun_slb_listener_status",
+			"slb 监听状态，当前状态为 1，其它状态为 0",
+			[]string{"account", "instance_id", "port", "protocol", "region", "status"},
+			nil,
+		),
+		CertificateExpiry: prometheus.NewDesc(
+			"aliyun_slb_certificate_expiry_timestamp_seconds",
+			"slb 证书的过期时间，type 为 server 或 ca",
+			[]string{"account", "certificate_id", "certificate_name", "common_name", "type", "region"},
+			nil,
+		),
+		ListenerCertificate: prometheus.NewDesc(
+			"aliyun_slb_listener_certificate_info",
+			"https 监听使用的证书，包括扩展域名的证书，值固定为 1，type 为 server 或 ca",
+			[]string{"account", "instance_id", "port", "certificate_id", "type", "region"},
+			nil,
+		),
+		ACLEntries: prometheus.NewDesc(
+			"aliyun_slb_acl_entries",
+			"slb 访问控制策略组中的条目数",
+			[]string{"account", "acl_id", "acl_name", "address_ip_version", "region"},
+			nil,
+		),
+		ACLListener: prometheus.NewDesc(
+			"aliyun_slb_acl_listener_info",
+			"访问控制策略组关联的监听，值固定为 1，acl_type 为 white 或 black",
+			[]string{"account", "acl_id", "instance_id", "port", "protocol", "acl_type", "region"},
+			nil,
+		),
+		ACLEntriesQuota: prometheus.NewDesc(
+			"aliyun_slb_acl_entries_quota",
+			"配额中心中单个访问控制策略组最多可以添加的条目数",
+			[]string{"account", "region"},
+			nil,
+		),
+	}
+}
+
+func (s *slbInventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.Info
+	ch <- s.Status
+	ch <- s.CreateTime
+	ch <- s.ListenerInfo
+	ch <- s.ListenerStatus
+	ch <- s.CertificateExpiry
+	ch <- s.ListenerCertificate
+	ch <- s.ACLEntries
+	ch <- s.ACLListener
+	ch <- s.ACLEntriesQuota
+}
+
+func (s *slbInventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	s.collectContext(context.Background(), ch)
+}
+
+func (s *slbInventoryCollector) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	s.sMutex.Lock()
+	defer s.sMutex.Unlock()
+
+	conf := currentConfig()
+	if !conf.collector("slb_inventory").enabled() {
+		return
+	}
+	start := time.Now()
+	success := forEachRegion(ctx, conf, func(ctx context.Context, account Account, region string) error {
+		return s.collectRegion(ctx, ch, conf, account, region)
+	})
+	observeCollector("slb_inventory", start, success)
+}
+
+func (s *slbInventoryCollector) collectRegion(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	// 刷新失败时继续输出上一次获取的实例列表
+	slbs, err := loadBalancers(ctx, conf, account, region)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe load balancers", "err", err, "account", account.Name, "region", region)
+	}
+	for _, v := range slbs {
+		instanceId := tea.StringValue(v.LoadBalancerId)
+		ch <- prometheus.MustNewConstMetric(s.Info, prometheus.GaugeValue, 1,
+			account.Name,
+			instanceId,
+			tea.StringValue(v.LoadBalancerName),
+			tea.StringValue(v.Address),
+			tea.StringValue(v.AddressType),
+			tea.StringValue(v.NetworkType),
+			tea.StringValue(v.VpcId),
+			tea.StringValue(v.VSwitchId),
+			tea.StringValue(v.LoadBalancerSpec),
+			tea.StringValue(v.MasterZoneId),
+			tea.StringValue(v.SlaveZoneId),
+			tea.StringValue(v.PayType),
+			region,
+		)
+
+		sendStatus(ch, s.Status, slbStatuses, tea.StringValue(v.LoadBalancerStatus), account.Name, instanceId, region)
+
+		if v.CreateTimeStamp != nil {
+			ch <- prometheus.MustNewConstMetric(s.CreateTime, prometheus.GaugeValue, float64(tea.Int64Value(v.CreateTimeStamp))/1000, account.Name, instanceId, region)
+		}
+	}
+
+	// 监听即使没有云监控数据点也会输出，用于发现已停止的监听
+	listeners, listenerErr := slbListeners(ctx, conf, account, region)
+	if listenerErr != nil {
+		level.Error(logger).Log("msg", "Failed to describe load balancer listeners", "err", listenerErr, "account", account.Name, "region", region)
+		err = listenerErr
+	}
+	var httpsListeners []*listener
+	for _, l := range listeners {
+		instanceId := tea.StringValue(l.LoadBalancerId)
+		port := fmt.Sprint(tea.Int32Value(l.ListenerPort))
+		protocol := tea.StringValue(l.ListenerProtocol)
+		hc := listenerHealthCheck(l)
+		ch <- prometheus.MustNewConstMetric(s.ListenerInfo, prometheus.GaugeValue, 1,
+			account.Name,
+			instanceId,
+			port,
+			protocol,
+			fmt.Sprint(tea.Int32Value(l.BackendServerPort)),
+			fmt.Sprint(tea.Int32Value(l.Bandwidth)),
+			tea.StringValue(l.Scheduler),
+			tea.StringValue(l.VServerGroupId),
+			hc.enabled,
+			hc.checkType,
+			hc.interval,
+			hc.healthyThreshold,
+			hc.unhealthyThreshold,
+			region,
+		)
+		sendStatus(ch, s.ListenerStatus, listenerStatuses, tea.StringValue(l.Status), account.Name, instanceId, port, protocol, region)
+
+		if c := l.HTTPSListenerConfig; c != nil {
+			if id := tea.StringValue(c.CACertificateId); id != "" {
+				ch <- prometheus.MustNewConstMetric(s.ListenerCertificate, prometheus.GaugeValue, 1, account.Name, instanceId, port, id, "ca", region)
+			}
+		}
+		if protocol == "https" {
+			httpsListeners = append(httpsListeners, l)
+		}
+	}
+	if extErr := s.collectListenerCertificates(ctx, ch, conf, account, region, httpsListeners); extErr != nil {
+		err = extErr
+	}
+
+	serverCertificates, certErr := slbServerCertificates(ctx, conf, account, region)
+	if certErr != nil {
+		level.Error(logger).Log("msg", "Failed to describe server certificates", "err", certErr, "account", account.Name, "region", region)
+		err = certErr
+	}
+	for _, c := range serverCertificates {
+		if c.ExpireTimeStamp == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.CertificateExpiry, prometheus.GaugeValue, float64(tea.Int64Value(c.ExpireTimeStamp))/1000,
+			account.Name, tea.StringValue(c.ServerCertificateId), tea.StringValue(c.ServerCertificateName), tea.StringValue(c.CommonName), "server", region)
+	}
+	caCertificates, certErr := slbCACertificates(ctx, conf, account, region)
+	if certErr != nil {
+		level.Error(logger).Log("msg", "Failed to describe ca certificates", "err", certErr, "account", account.Name, "region", region)
+		err = certErr
+	}
+	for _, c := range caCertificates {
+		if c.ExpireTimeStamp == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(s.CertificateExpiry, prometheus.GaugeValue, float64(tea.Int64Value(c.ExpireTimeStamp))/1000,
+			account.Name, tea.StringValue(c.CACertificateId), tea.StringValue(c.CACertificateName), tea.StringValue(c.CommonName), "ca", region)
+	}
+
+	if aclErr := s.collectACLs(ctx, ch, conf, account, region); aclErr != nil {
+		err = aclErr
+	}
+	// 配额是可选的，获取失败不影响 collector 的状态
+	s.collectQuotas(ctx, ch, conf, account, region)
+	return err
+}
+
+// collectListenerCertificates 输出 https 监听的默认服务器证书和扩展域名（SNI）使用的服务器证书，
+// 扩展域名按 inventory_interval 缓存，与默认证书相同的只输出一次
+func (s *slbInventoryCollector) collectListenerCertificates(ctx context.Context, ch chan<- prometheus.Metric, conf *Config,
+	account Account, region string, listeners []*listener) (err error) {
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+	)
+	for _, l := range listeners {
+		l := l
+		instanceId := tea.StringValue(l.LoadBalancerId)
+		port := fmt.Sprint(tea.Int32Value(l.ListenerPort))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			certificates := make(map[string]bool)
+			if c := l.HTTPSListenerConfig; c != nil && tea.StringValue(c.ServerCertificateId) != "" {
+				certificates[tea.StringValue(c.ServerCertificateId)] = true
+			}
+
+			var extensions []*domainExtension
+			var extErr error
+			runErr := apiPool.run(ctx, conf.concurrency(), func() {
+				extensions, extErr = slbDomainExtensions(ctx, conf, account, region, instanceId, tea.Int32Value(l.ListenerPort))
+			})
+			if runErr != nil {
+				extErr = runErr
+			}
+			if extErr != nil {
+				level.Error(logger).Log("msg", "Failed to describe domain extensions", "err", extErr, "instance_id", instanceId, "port", port, "account", account.Name, "region", region)
+				errMutex.Lock()
+				err = extErr
+				errMutex.Unlock()
+			}
+			for _, e := range extensions {
+				if id := tea.StringValue(e.ServerCertificateId); id != "" {
+					certificates[id] = true
+				}
+			}
+
+			for id := range certificates {
+				ch <- prometheus.MustNewConstMetric(s.ListenerCertificate, prometheus.GaugeValue, 1, account.Name, instanceId, port, id, "server", region)
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// collectACLs 输出访问控制策略组的条目数和关联的监听，条目数接近配额时新增条目会失败
+func (s *slbInventoryCollector) collectACLs(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) error {
+	acls, err := slbAccessControlLists(ctx, conf, account, region)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to describe access control lists", "err", err, "account", account.Name, "region", region)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+	)
+	for _, acl := range acls {
+		aclId := tea.StringValue(acl.AclId)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var attribute *accessControlListAttribute
+			var attrErr error
+			runErr := apiPool.run(ctx, conf.concurrency(), func() {
+				attribute, attrErr = slbAccessControlListAttribute(ctx, conf, account, region, aclId)
+			})
+			if runErr != nil {
+				attrErr = runErr
+			}
+			if attrErr != nil {
+				level.Error(logger).Log("msg", "Failed to describe access control list attribute", "err", attrErr, "acl_id", aclId, "account", account.Name, "region", region)
+				errMutex.Lock()
+				err = attrErr
+				errMutex.Unlock()
+			}
+			if attribute == nil {
+				return
+			}
+
+			entries := 0
+			if attribute.AclEntrys != nil {
+				entries = len(attribute.AclEntrys.AclEntry)
+			}
+			ch <- prometheus.MustNewConstMetric(s.ACLEntries, prometheus.GaugeValue, float64(entries),
+				account.Name, aclId, tea.StringValue(attribute.AclName), tea.StringValue(attribute.AddressIPVersion), region)
+			if attribute.RelatedListeners == nil {
+				return
+			}
+			for _, l := range attribute.RelatedListeners.RelatedListener {
+				ch <- prometheus.MustNewConstMetric(s.ACLListener, prometheus.GaugeValue, 1,
+					account.Name, aclId, tea.StringValue(l.LoadBalancerId), fmt.Sprint(tea.Int32Value(l.ListenerPort)),
+					tea.StringValue(l.Protocol), tea.StringValue(l.AclType), region)
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// collectQuotas 输出配额中心中当前地域访问控制策略组的条目数配额，地域级配额优先于账号级配额
+func (s *slbInventoryCollector) collectQuotas(ctx context.Context, ch chan<- prometheus.Metric, conf *Config, account Account, region string) {
+	quotas, err := slbQuotas(ctx, conf, account)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to list product quotas", "err", err, "product", "slb", "account", account.Name)
+	}
+	var quota *productQuota
+	for _, q := range quotas {
+		if !isACLEntriesQuota(q) {
+			continue
+		}
+		regional, ok := quotaScope(q, region)
+		if ok && (quota == nil || regional) {
+			quota = q
+		}
+	}
+	if quota != nil {
+		ch <- prometheus.MustNewConstMetric(s.ACLEntriesQuota, prometheus.GaugeValue, quota.TotalQuota, account.Name, region)
+	}
+}
+
+// isACLEntriesQuota 判断是否为单个访问控制策略组的条目数配额，配额 ID 中包含 acl 和 entry，
+// 或配额名称中包含访问控制和条目
+func isACLEntriesQuota(q *productQuota) bool {
+	code := strings.ToLower(q.QuotaActionCode)
+	if strings.Contains(code, "acl") && strings.Contains(code, "entr") {
+		return true
+	}
+	return strings.Contains(q.QuotaName, "访问控制") && strings.Contains(q.QuotaName, "条目")
+}
+
+// quotaScope 判断配额是否适用于 region：没有维度的账号级配额适用于所有地域，只有 regionId 维度的配额
+// 适用于对应地域，有其它维度的配额不适用；regional 表示是否为地域级配额
+func quotaScope(q *productQuota, region string) (regional bool, ok bool) {
+	switch len(q.Dimensions) {
+	case 0:
+		return false, true
+	case 1:
+		quotaRegion, ok := q.Dimensions["regionId"]
+		return true, ok && quotaRegion == region
+	default:
+		return false, false
+	}
+}
+
+type listenerHealthCheckConfig struct {
+	enabled            string
+	checkType          string
+	interval           string
+	healthyThreshold   string
+	unhealthyThreshold string
+}
+
+// listenerHealthCheck 从对应协议的监听配置中取健康检查配置，没有配置的字段为空
+func listenerHealthCheck(l *listener) listenerHealthCheckConfig {
+	format := func(v *int32) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(*v)
+	}
+	switch {
+	case l.HTTPListenerConfig != nil:
+		c := l.HTTPListenerConfig
+		return listenerHealthCheckConfig{tea.StringValue(c.HealthCheck), tea.StringValue(c.HealthCheckType),
+			format(c.HealthCheckInterval), format(c.HealthyThreshold), format(c.UnhealthyThreshold)}
+	case l.HTTPSListenerConfig != nil:
+		c := l.HTTPSListenerConfig
+		return listenerHealthCheckConfig{tea.StringValue(c.HealthCheck), tea.StringValue(c.HealthCheckType),
+			format(c.HealthCheckInterval), format(c.HealthyThreshold), format(c.UnhealthyThreshold)}
+	case l.TCPListenerConfig != nil:
+		c := l.TCPListenerConfig
+		return listenerHealthCheckConfig{tea.StringValue(c.HealthCheck), tea.StringValue(c.HealthCheckType),
+			format(c.HealthCheckInterval), format(c.HealthyThreshold), format(c.UnhealthyThreshold)}
+	case l.UDPListenerConfig != nil:
+		c := l.UDPListenerConfig
+		return listenerHealthCheckConfig{tea.StringValue(c.HealthCheck), "",
+			format(c.HealthCheckInterval), format(c.HealthyThreshold), format(c.UnhealthyThreshold)}
+	}
+	return listenerHealthCheckConfig{}
+}
+
+// sendStatus 对 statuses 中的每个状态输出一条，当前状态 status 为 1，其它为 0，不在 statuses 中的当前状态也会输出。
+// desc 的最后一个标签为 status
+func sendStatus(ch chan<- prometheus.Metric, desc *prometheus.Desc, statuses []string, status string, labelValues ...string) {
+	if !contains(statuses, status) {
+		statuses = append(append([]string{}, statuses...), status)
+	}
+	for _, st := range statuses {
+		value := 0.0
+		if st == status {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append(append([]string{}, labelValues...), st)...)
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import "testing"
+
+func TestQuotaScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions map[string]interface{}
+		regional   bool
+		ok         bool
+	}{
+		{name: "account quota", ok: true},
+		{name: "empty dimensions", dimensions: map[string]interface{}{}, ok: true},
+		{name: "same region", dimensions: map[string]interface{}{"regionId": "cn-hangzhou"}, regional: true, ok: true},
+		{name: "other region", dimensions: map[string]interface{}{"regionId": "cn-beijing"}, regional: true, ok: false},
+		{name: "other dimension", dimensions: map[string]interface{}{"zoneId": "cn-hangzhou-h"}, regional: true, ok: false},
+		{name: "region and other dimension", dimensions: map[string]interface{}{"regionId": "cn-hangzhou", "zoneId": "cn-hangzhou-h"}, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regional, ok := quotaScope(&productQuota{Dimensions: tt.dimensions}, "cn-hangzhou")
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if ok && regional != tt.regional {
+				t.Errorf("expected regional %v, got %v", tt.regional, regional)
+			}
+		})
+	}
+}
+
+func TestIsACLEntriesQuota(t *testing.T) {
+	tests := []struct {
+		code string
+		name string
+		want bool
+	}{
+		{code: "slb_quota_acl_entries_num", want: true},
+		{code: "SLB_QUOTA_ACL_ENTRYS_NUM", want: true},
+		{code: "q_custom", name: "单个访问控制策略组可添加的条目数", want: true},
+		{code: "slb_quota_acls_num", name: "可创建的访问控制策略组个数", want: false},
+		{code: "slb_quota_instances_num", name: "可保有的负载均衡实例个数", want: false},
+	}
+	for _, tt := range tests {
+		if got := isACLEntriesQuota(&productQuota{QuotaActionCode: tt.code, QuotaName: tt.name}); got != tt.want {
+			t.Errorf("isACLEntriesQuota(%q, %q) = %v, want %v", tt.code, tt.name, got, tt.want)
+		}
+	}
+}
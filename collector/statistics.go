@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 云监控数据点中可以选择的统计值
+var statisticNames = []string{"Average", "Maximum", "Minimum", "Value", "Sum"}
+
+const (
+	statisticModeLabel  = "label"
+	statisticModeSuffix = "suffix"
+)
+
+type descMeta struct {
+	fqName         string
+	help           string
+	variableLabels []string
+	constLabels    prometheus.Labels
+}
+
+var (
+	descMetas    sync.Map
+	derivedDescs sync.Map
+)
+
+// newDesc 与 prometheus.NewDesc 相同，额外记录名称和标签，用于生成带统计值的 Desc
+func newDesc(fqName, help string, variableLabels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	desc := prometheus.NewDesc(fqName, help, variableLabels, constLabels)
+	descMetas.Store(desc, descMeta{fqName: fqName, help: help, variableLabels: variableLabels, constLabels: constLabels})
+	return desc
+}
+
+// statisticDesc 返回 desc 对应统计值的 Desc，label 模式增加 statistic 标签，suffix 模式在名称后加 _maximum 等后缀
+func statisticDesc(desc *prometheus.Desc, statistic string, mode string) *prometheus.Desc {
+	key := fmt.Sprintf("%p/%s/%s", desc, statistic, mode)
+	if d, ok := derivedDescs.Load(key); ok {
+		return d.(*prometheus.Desc)
+	}
+
+	m, ok := descMetas.Load(desc)
+	if !ok {
+		return desc
+	}
+	meta := m.(descMeta)
+	var derived *prometheus.Desc
+	if mode == statisticModeSuffix {
+		derived = prometheus.NewDesc(meta.fqName+"_"+strings.ToLower(statistic), meta.help+"，统计值 "+statistic, meta.variableLabels, meta.constLabels)
+	} else {
+		labels := append(append([]string{}, meta.variableLabels...), "statistic")
+		derived = prometheus.NewDesc(meta.fqName, meta.help, labels, meta.constLabels)
+	}
+	d, _ := derivedDescs.LoadOrStore(key, derived)
+	return d.(*prometheus.Desc)
+}
+
+// metricStatistics 返回指标需要输出的统计值，为空表示保持默认行为
+func (c CollectorConfig) metricStatistics(metricName string) []string {
+	if stats, ok := c.MetricStatistics[metricName]; ok {
+		return stats
+	}
+	return c.Statistics
+}
+
+// sendDatapoint 输出一个数据点。没有配置统计值时，按 defaultStatistics 的顺序取第一个存在的值，
+// 指标名称和标签保持不变；配置了统计值时，每个统计值输出一条。没有输出任何指标时返回 false
+func sendDatapoint(ch chan<- prometheus.Metric, desc *prometheus.Desc, d datapoint,
+	collectorConf CollectorConfig, metricName string, defaultStatistics []string, labelValues ...string) bool {
+	sent := false
+	send := func(desc *prometheus.Desc, value float64, labelValues []string) {
+		m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+		if err != nil {
+			level.Debug(logger).Log("msg", "Invalid datapoint", "metric", metricName, "err", err)
+			return
+		}
+		if d.timestamp != 0 && collectorConf.Timestamps {
+			m = prometheus.NewMetricWithTimestamp(time.UnixMilli(d.timestamp), m)
+		}
+		ch <- m
+		sent = true
+	}
+
+	stats := collectorConf.metricStatistics(metricName)
+	if len(stats) == 0 {
+		for _, statistic := range defaultStatistics {
+			if value, ok := d.value(statistic); ok {
+				send(desc, value, labelValues)
+				break
+			}
+		}
+		return sent
+	}
+
+	for _, statistic := range stats {
+		value, ok := d.value(statistic)
+		if !ok {
+			continue
+		}
+		if collectorConf.StatisticMode == statisticModeSuffix {
+			send(statisticDesc(desc, statistic, statisticModeSuffix), value, labelValues)
+		} else {
+			send(statisticDesc(desc, statistic, statisticModeLabel), value, append(labelValues, statistic))
+		}
+	}
+	return sent
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// datapointCollector 采集时调用 sendDatapoint 输出一个数据点，Describe 为空，输出的 Desc 由统计值决定
+type datapointCollector struct {
+	desc              *prometheus.Desc
+	point             datapoint
+	conf              CollectorConfig
+	defaultStatistics []string
+	sent              bool
+}
+
+func (c *datapointCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (c *datapointCollector) Collect(ch chan<- prometheus.Metric) {
+	c.sent = sendDatapoint(ch, c.desc, c.point, c.conf, "TestMetric", c.defaultStatistics, "lb-1")
+}
+
+func TestSendDatapoint(t *testing.T) {
+	point := datapoint{
+		timestamp:  1666000000000,
+		dimensions: map[string]string{"instanceId": "lb-1"},
+		values:     map[string]float64{"Average": 1, "Maximum": 3, "Minimum": 0},
+	}
+	tests := []struct {
+		name              string
+		conf              CollectorConfig
+		defaultStatistics []string
+		// 期望的输出，为空时期望没有输出任何指标
+		expected string
+	}{
+		{
+			name:              "default statistic",
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1"} 1
+`,
+		},
+		{
+			name:              "default statistic fallback",
+			defaultStatistics: []string{"Value", "Maximum", "Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1"} 3
+`,
+		},
+		{
+			name:              "missing default statistics",
+			defaultStatistics: []string{"Value", "Sum"},
+		},
+		{
+			name:              "label mode",
+			conf:              CollectorConfig{Statistics: []string{"Average", "Maximum"}},
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1",statistic="Average"} 1
+aliyun_test{instance_id="lb-1",statistic="Maximum"} 3
+`,
+		},
+		{
+			name:              "suffix mode",
+			conf:              CollectorConfig{Statistics: []string{"Maximum", "Minimum"}, StatisticMode: statisticModeSuffix},
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test_maximum help，统计值 Maximum
+# TYPE aliyun_test_maximum gauge
+aliyun_test_maximum{instance_id="lb-1"} 3
+# HELP aliyun_test_minimum help，统计值 Minimum
+# TYPE aliyun_test_minimum gauge
+aliyun_test_minimum{instance_id="lb-1"} 0
+`,
+		},
+		{
+			name: "metric statistics override statistics",
+			conf: CollectorConfig{
+				Statistics:       []string{"Average"},
+				MetricStatistics: map[string][]string{"TestMetric": {"Maximum"}, "OtherMetric": {"Minimum"}},
+			},
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1",statistic="Maximum"} 3
+`,
+		},
+		{
+			name:              "missing configured statistic",
+			conf:              CollectorConfig{Statistics: []string{"Sum", "Average"}},
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1",statistic="Average"} 1
+`,
+		},
+		{
+			name:              "all configured statistics missing",
+			conf:              CollectorConfig{Statistics: []string{"Sum", "Value"}},
+			defaultStatistics: []string{"Average"},
+		},
+		{
+			name:              "timestamps",
+			conf:              CollectorConfig{Timestamps: true},
+			defaultStatistics: []string{"Average"},
+			expected: `
+# HELP aliyun_test help
+# TYPE aliyun_test gauge
+aliyun_test{instance_id="lb-1"} 1 1666000000000
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &datapointCollector{
+				desc:              newDesc("aliyun_test", "help", []string{"instance_id"}, nil),
+				point:             point,
+				conf:              tt.conf,
+				defaultStatistics: tt.defaultStatistics,
+			}
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(c)
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tt.expected)); err != nil {
+				t.Error(err)
+			}
+			if want := tt.expected != ""; c.sent != want {
+				t.Errorf("expected sent %v, got %v", want, c.sent)
+			}
+		})
+	}
+}